@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// maxBatchKeys limits the number of keys in a batch lookup.
+const maxBatchKeys = 1000
+
+// BatchHandler handles a POST of many keys to look up at once in the negotiated language.
+// Each key can have printf arguments and a count, which picks the plural form and is its argument if no others are given.
+type BatchHandler struct {
+	ST *loader.StringTable
+	// Negotiator matches request languages to the loaded ones, with the defaults if nil.
+	Negotiator *Negotiator
+	// Missing records lookups of keys without a translation, if set.
+	Missing *MissingKeys
+	// Usage counts lookups of keys with a translation, if set.
+	Usage *KeyUsage
+}
+
+// batchKey is a key to look up, given either as a string or as an object with arguments.
+type batchKey struct {
+	Key   string        `json:"key"`
+	Args  []interface{} `json:"args,omitempty"`
+	Count *int          `json:"count,omitempty"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (k *batchKey) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &k.Key); err == nil {
+		return nil
+	}
+
+	type plain batchKey
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode((*plain)(k)); err != nil {
+		return err
+	}
+	for i, a := range k.Args {
+		if n, ok := a.(json.Number); ok {
+			k.Args[i] = jsonNumberArg(n)
+		}
+	}
+	return nil
+}
+
+// jsonNumberArg converts a JSON number to an int if it is one, so that it formats with %d.
+func jsonNumberArg(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+type batchRequest struct {
+	Keys []batchKey `json:"keys"`
+}
+
+type batchString struct {
+	Key         string `json:"key"`
+	Translation string `json:"translation"`
+	Missing     bool   `json:"missing,omitempty"`
+}
+
+type batchResponse struct {
+	Language string        `json:"language"`
+	Strings  []batchString `json:"strings"`
+}
+
+func (h BatchHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var batch batchRequest
+	err := json.NewDecoder(http.MaxBytesReader(res, req.Body, maxEditSize)).Decode(&batch)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - Body must be a JSON object with a list of keys"))
+		return
+	}
+	if len(batch.Keys) > maxBatchKeys {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - At most " + strconv.Itoa(maxBatchKeys) + " keys can be looked up at once"))
+		return
+	}
+
+	lang, accept, param := ExtractLang(req)
+	tag := h.Negotiator.negotiate(h.ST, req).Tag
+	log.Debug().
+		Str("param", param).
+		Str("cookie", lang).
+		Str("accept", accept).
+		Int("keys", len(batch.Keys)).
+		Str("language_tag", tag.String()).
+		Msg("Returning batch of strings")
+
+	cat, err := h.ST.StringsByTag(tag)
+	if err != nil {
+		cat = loader.NewStringCatalog(h.ST.LoadedAt())
+	}
+
+	p := message.NewPrinter(tag)
+	data := batchResponse{Language: tag.String(), Strings: []batchString{}}
+	for _, k := range batch.Keys {
+		_, ok := cat.Strings[k.Key]
+		if ok {
+			h.Usage.Hit(k.Key, tag)
+		} else {
+			h.Missing.Record(k.Key, tag, req.Referer(), false)
+		}
+		data.Strings = append(data.Strings, batchString{
+			Key:         k.Key,
+			Translation: resolve(p, tag, k, cat.Metadata[k.Key]),
+			Missing:     !ok,
+		})
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(res).Encode(data)
+	if err != nil {
+		log.Error().Err(err).Msg("Unexpected error writing batch of strings")
+	}
+}
+
+// resolve formats the translation of a key, in the plural form for its count if it has one.
+func resolve(p *message.Printer, tag language.Tag, k batchKey, meta map[string]string) string {
+	args := k.Args
+	if k.Count == nil {
+		return p.Sprintf(k.Key, args...)
+	}
+	if len(args) == 0 {
+		args = []interface{}{*k.Count}
+	}
+
+	if form, ok := meta[loader.MetadataPluralPrefix+"="+strconv.Itoa(*k.Count)]; ok {
+		return p.Sprintf(form, args...)
+	}
+	if form, ok := meta[loader.MetadataPluralPrefix+pluralCategory(tag, *k.Count)]; ok {
+		return p.Sprintf(form, args...)
+	}
+	return p.Sprintf(k.Key, args...)
+}
+
+var pluralCategories = map[plural.Form]string{
+	plural.Other: "other",
+	plural.Zero:  "zero",
+	plural.One:   "one",
+	plural.Two:   "two",
+	plural.Few:   "few",
+	plural.Many:  "many",
+}
+
+// pluralCategory gets the CLDR plural category of a whole number in a language.
+func pluralCategory(tag language.Tag, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	return pluralCategories[plural.Cardinal.MatchPlural(tag, n, 0, 0, 0, 0)]
+}
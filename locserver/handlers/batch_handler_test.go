@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchHandler(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello", "Welcome, %s!", "Welcome, %s!"),
+		"pl-pl/messages.json": &fstest.MapFile{Data: []byte(`{"language": "pl-pl", "messages": [
+			{"id": "hello", "message": "hello", "translation": "Cześć"},
+			{"id": "Welcome, %s!", "message": "Welcome, %s!", "translation": "Witaj, %s!"},
+			{"id": "%d files", "message": "%d files", "translation": {"select": {"feature": "plural", "arg": "Count", "cases": {
+				"=0": "Brak plików",
+				"one": "%d plik",
+				"few": "%d pliki",
+				"many": "%d plików",
+				"other": "%d pliku"
+			}}}}
+		]}`)},
+	})
+	h := BatchHandler{ST: st}
+
+	post := func(target, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		return res
+	}
+
+	res := post("/v1/strings:batch?lang=pl-pl", `{"keys": [
+		"hello",
+		{"key": "Welcome, %s!", "args": ["Ola"]},
+		{"key": "%d files", "count": 0},
+		{"key": "%d files", "count": 1},
+		{"key": "%d files", "count": 3},
+		{"key": "%d files", "count": 25},
+		"goodbye"
+	]}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+
+	var body batchResponse
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, "pl-PL", body.Language)
+	assert.Equal(t, []batchString{
+		{Key: "hello", Translation: "Cześć"},
+		{Key: "Welcome, %s!", Translation: "Witaj, Ola!"},
+		{Key: "%d files", Translation: "Brak plików"},
+		{Key: "%d files", Translation: "1 plik"},
+		{Key: "%d files", Translation: "3 pliki"},
+		{Key: "%d files", Translation: "25 plików"},
+		{Key: "goodbye", Translation: "goodbye", Missing: true},
+	}, body.Strings)
+
+	res = post("/v1/strings:batch?lang=en-us", `{"keys": "hello"}`)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = post("/v1/strings:batch?lang=en-us", `{"keys": [`+strings.Repeat(`"hello",`, maxBatchKeys)+`"hello"]}`)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
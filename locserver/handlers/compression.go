@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+)
+
+// DefaultCompressionMinSize is the smallest response body worth compressing.
+const DefaultCompressionMinSize = 1024
+
+// brotliLevel trades some compression for speed, since responses are compressed as they are served.
+const brotliLevel = 5
+
+// NewEncoder wraps a writer in a compressor for a content coding.
+type NewEncoder func(w io.Writer) io.WriteCloser
+
+type encoding struct {
+	name       string
+	newEncoder NewEncoder
+}
+
+var (
+	encodingsMu sync.RWMutex
+	// encodings are the supported content codings, preferred in order when a client accepts several equally.
+	encodings = []encoding{
+		{name: "br", newEncoder: func(w io.Writer) io.WriteCloser { return brotli.NewWriterLevel(w, brotliLevel) }},
+		{name: "gzip", newEncoder: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{name: "deflate", newEncoder: func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}},
+	}
+)
+
+// RegisterEncoding adds a content coding, such as zstd, to compress responses with.
+// It is preferred over the codings registered before it.
+func RegisterEncoding(name string, newEncoder NewEncoder) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	encodings = append([]encoding{{name: name, newEncoder: newEncoder}}, encodings...)
+}
+
+// NegotiateEncoding gets the supported content coding the client accepts most, from the Accept-Encoding header
+// of a request and its q values, or "" for none.
+func NegotiateEncoding(req *http.Request) string {
+	accepted := acceptedEncodings(req)
+
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	best, bestQ := "", 0.0
+	for _, e := range encodings {
+		q, ok := accepted[e.name]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = e.name, q
+		}
+	}
+	return best
+}
+
+// acceptsEncoding checks whether the Accept-Encoding header of a request allows a content coding.
+func acceptsEncoding(req *http.Request, name string) bool {
+	accepted := acceptedEncodings(req)
+	q, ok := accepted[name]
+	if !ok {
+		q = accepted["*"]
+	}
+	return q > 0
+}
+
+// acceptedEncodings gets the q value of each content coding in the Accept-Encoding header of a request.
+func acceptedEncodings(req *http.Request) map[string]float64 {
+	accepted := map[string]float64{}
+	for _, part := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v := strings.TrimSpace(f); strings.HasPrefix(v, "q=") {
+				q, _ = strconv.ParseFloat(v[2:], 64)
+			}
+		}
+		accepted[name] = q
+	}
+	return accepted
+}
+
+func lookupEncoding(name string) NewEncoder {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	for _, e := range encodings {
+		if e.name == name {
+			return e.newEncoder
+		}
+	}
+	return nil
+}
+
+// CompressionMiddleware compresses response bodies of at least minSize bytes in the content coding the client
+// accepts most. Responses that already have a Content-Encoding, such as pre-compressed ones, are left alone.
+func CompressionMiddleware(minSize int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			name := NegotiateEncoding(req)
+			if name == "" || req.Method == http.MethodHead {
+				addVary(res.Header(), "Accept-Encoding")
+				next.ServeHTTP(res, req)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: res, encoding: name, minSize: minSize}
+			defer cw.Close()
+			next.ServeHTTP(cw, req)
+		})
+	}
+}
+
+// compressWriter holds back the start of a response until it knows whether the body is big enough to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = status
+	if status != http.StatusOK {
+		// Partial, empty and error responses go out as they are.
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) >= w.minSize {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide writes the header, compressing the body if it is worth it and nothing else has encoded it.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Encoding") == "" {
+		addVary(header, "Accept-Encoding")
+	} else {
+		compress = false
+	}
+	if compress && !isCompressible(header.Get("Content-Type")) {
+		compress = false
+	}
+	if compress {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", weakETag(etag))
+		}
+		w.encoder = lookupEncoding(w.encoding)(w.ResponseWriter)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush implements http.Flusher, sending what is held back.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buf) >= w.minSize)
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the response, sending a body too small to compress as it is.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 {
+			// Nothing was written; let the server send its default response.
+			return nil
+		}
+		return w.decide(false)
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+// isCompressible checks whether a content type is text that compresses well, rather than already compressed data.
+func isCompressible(contentType string) bool {
+	t := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return strings.HasPrefix(t, "text/") ||
+		strings.HasSuffix(t, "json") || strings.HasSuffix(t, "xml") ||
+		t == "application/javascript" || t == "application/typescript" || t == "application/yaml"
+}
+
+// addVary adds a request header to the Vary header of a response, unless it is there already.
+func addVary(header http.Header, name string) {
+	for _, v := range header.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                       "",
+		"gzip":                   "gzip",
+		"deflate, gzip":          "gzip",
+		"deflate, gzip;q=0.5":    "deflate",
+		"br":                     "br",
+		"gzip, deflate, br":      "br",
+		"br;q=0.5, gzip":         "gzip",
+		"*":                      "br",
+		"*, br;q=0, gzip;q=0":    "deflate",
+		"identity, GZIP;q=0.001": "gzip",
+		"zstd":                   "",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		assert.Equal(t, want, NegotiateEncoding(req), accept)
+	}
+}
+
+func newCompressionRouter(handler http.Handler) *mux.Router {
+	router := mux.NewRouter()
+	router.Handle("/", handler)
+	router.Use(CompressionMiddleware(64))
+	return router
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	body := strings.Repeat("compress me ", 20)
+	router := newCompressionRouter(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain")
+		res.Header().Set("ETag", `"abc"`)
+		res.Write([]byte(body[:len(body)/2]))
+		res.Write([]byte(body[len(body)/2:]))
+	}))
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := get("gzip")
+	assert.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", res.Header().Get("Vary"))
+	assert.Equal(t, `W/"abc"`, res.Header().Get("ETag"))
+	r, err := gzip.NewReader(res.Body)
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(data))
+
+	res = get("br")
+	assert.Equal(t, "br", res.Header().Get("Content-Encoding"))
+	data, err = ioutil.ReadAll(brotli.NewReader(res.Body))
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(data))
+
+	res = get("deflate")
+	assert.Equal(t, "deflate", res.Header().Get("Content-Encoding"))
+	data, err = ioutil.ReadAll(flate.NewReader(res.Body))
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(data))
+
+	res = get("")
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", res.Header().Get("Vary"))
+	assert.Equal(t, body, res.Body.String())
+}
+
+func TestCompressionMiddlewareSkips(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"small": func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set("Content-Type", "text/plain")
+			res.Write([]byte("tiny"))
+		},
+		"binary": func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set("Content-Type", "image/png")
+			res.Write(make([]byte, 256))
+		},
+		"error": func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set("Content-Type", "text/plain")
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(strings.Repeat("not found ", 20)))
+		},
+	} {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		newCompressionRouter(handler).ServeHTTP(res, req)
+		assert.Empty(t, res.Header().Get("Content-Encoding"), name)
+	}
+}
+
+func TestCompressionMiddlewareReusesPreGzipped(t *testing.T) {
+	idsAndTranslations := []string{}
+	for _, id := range strings.Split("abcdefghijklmnopqrstuvwxyz", "") {
+		idsAndTranslations = append(idsAndTranslations, id, strings.Repeat(id, 10))
+	}
+	st := newTestStringTable(t, fstest.MapFS{"en-us/messages.json": gotextFile("en-us", idsAndTranslations...)})
+	router := newCompressionRouter(StringsHandler{ST: st, Cache: NewResponseCache(st, true)})
+
+	// The gzipped copy wins over compressing again with a coding the client prefers.
+	req := httptest.NewRequest(http.MethodGet, "/?lang=en-us", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+	etag := res.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, "W/"), etag)
+	r, err := gzip.NewReader(res.Body)
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "a,aaaaaaaaaa\n"), string(data))
+
+	// The weak tag of the compressed body revalidates.
+	req.Header.Set("If-None-Match", etag)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotModified, res.Code)
+}
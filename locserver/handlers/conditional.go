@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ETag gets a strong entity tag for a response body of a content type.
+func ETag(contentType string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(contentType))
+	h.Write([]byte{0})
+	h.Write(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:18]) + `"`
+}
+
+// weakETag gets the entity tag of a response body in a content encoding. The bytes differ, so it is only weakly
+// equal to the tag of the body, which still matches it in If-None-Match.
+func weakETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return "W/" + etag
+}
+
+// serveContent writes a complete response body with its ETag and modification time, answering conditional
+// requests (If-None-Match, If-Modified-Since) with 304 Not Modified. The Content-Type must already be set.
+func serveContent(res http.ResponseWriter, req *http.Request, etag string, modTime time.Time, cacheControl string, body []byte) {
+	res.Header().Set("ETag", etag)
+	if cacheControl != "" {
+		res.Header().Set("Cache-Control", cacheControl)
+	}
+	http.ServeContent(res, req, "", modTime, bytes.NewReader(body))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringsHandlerConditionalRequests(t *testing.T) {
+	modTime := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	file := gotextFile("en-us", "home.title", "Home", "about", "About")
+	file.ModTime = modTime
+	st := newTestStringTable(t, fstest.MapFS{"en-us/messages.json": file})
+	h := StringsHandler{ST: st, CacheControl: "public, max-age=60"}
+
+	get := func(target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	res := get("/v1/strings?lang=en-us")
+	assert.Equal(t, http.StatusOK, res.Code)
+	etag := res.Header().Get("ETag")
+	assert.Regexp(t, `^"[A-Za-z0-9_-]+"$`, etag)
+	assert.Equal(t, modTime.Format(http.TimeFormat), res.Header().Get("Last-Modified"))
+	assert.Equal(t, "Accept, Accept-Language, Cookie", res.Header().Get("Vary"))
+	assert.Equal(t, "public, max-age=60", res.Header().Get("Cache-Control"))
+	assert.Equal(t, "about,About\nhome.title,Home\n", res.Body.String())
+
+	// The same catalog gets the same tag, and a different format or filter a different one.
+	assert.Equal(t, etag, get("/v1/strings?lang=en-us").Header().Get("ETag"))
+	assert.NotEqual(t, etag, get("/v1/strings?lang=en-us&fmt=application/json").Header().Get("ETag"))
+	assert.NotEqual(t, etag, get("/v1/strings?lang=en-us&kf=home").Header().Get("ETag"))
+
+	res = get("/v1/strings?lang=en-us", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, res.Code)
+	assert.Empty(t, res.Body.String())
+	assert.Equal(t, etag, res.Header().Get("ETag"))
+	assert.Equal(t, "Accept, Accept-Language, Cookie", res.Header().Get("Vary"))
+
+	res = get("/v1/strings?lang=en-us", "If-None-Match", `"stale"`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = get("/v1/strings?lang=en-us", "If-Modified-Since", modTime.Add(time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, http.StatusNotModified, res.Code)
+
+	res = get("/v1/strings?lang=en-us", "If-Modified-Since", modTime.Add(-time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// If-None-Match takes precedence over If-Modified-Since.
+	res = get("/v1/strings?lang=en-us", "If-None-Match", `"stale"`, "If-Modified-Since", modTime.Format(http.TimeFormat))
+	assert.Equal(t, http.StatusOK, res.Code)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/language"
+)
+
+// maxEditSize limits the size of a request body that changes strings.
+const maxEditSize = 10 << 20
+
+// EditStringHandler handles a change to an individual string.
+// PUT sets its translation to the request body and DELETE removes it, in the language given by the lang query param.
+type EditStringHandler struct {
+	ST *loader.StringTable
+}
+
+func (h EditStringHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	tag, ok := editTag(res, req)
+	if !ok {
+		return
+	}
+
+	edit := loader.Edit{Key: mux.Vars(req)["str"]}
+	switch req.Method {
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(res, req.Body, maxEditSize))
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("400 - Unable to read translation"))
+			return
+		}
+		edit.Value = string(body)
+	case http.MethodDelete:
+		edit.Delete = true
+	default:
+		res.WriteHeader(http.StatusMethodNotAllowed)
+		res.Write([]byte("405 - Method not allowed"))
+		return
+	}
+
+	writeUpdate(res, tag, h.ST.Update(tag, []loader.Edit{edit}))
+}
+
+// EditStringsHandler handles a PATCH of many strings in the language given by the lang query param.
+// The body is a JSON merge patch (RFC 7396) of the catalog: an object of ids to translations, where null removes the string.
+type EditStringsHandler struct {
+	ST *loader.StringTable
+}
+
+func (h EditStringsHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	tag, ok := editTag(res, req)
+	if !ok {
+		return
+	}
+
+	patch := map[string]*string{}
+	err := json.NewDecoder(http.MaxBytesReader(res, req.Body, maxEditSize)).Decode(&patch)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - Body must be a JSON object of ids to translations"))
+		return
+	}
+
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	edits := []loader.Edit{}
+	for _, k := range keys {
+		if patch[k] == nil {
+			edits = append(edits, loader.Edit{Key: k, Delete: true})
+		} else {
+			edits = append(edits, loader.Edit{Key: k, Value: *patch[k]})
+		}
+	}
+
+	writeUpdate(res, tag, h.ST.Update(tag, edits))
+}
+
+// editTag gets the language to change from the lang query param. Unlike reads, it is not negotiated.
+func editTag(res http.ResponseWriter, req *http.Request) (language.Tag, bool) {
+	tag, err := language.Parse(GetQueryParam(req, "lang"))
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - A valid lang query param is required"))
+		return tag, false
+	}
+	return tag, true
+}
+
+func writeUpdate(res http.ResponseWriter, tag language.Tag, err error) {
+	switch {
+	case err == nil:
+		res.WriteHeader(http.StatusNoContent)
+		return
+	case errors.Is(err, loader.ErrReadOnly):
+		res.WriteHeader(http.StatusForbidden)
+	case errors.Is(err, loader.ErrNotFound):
+		res.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, loader.ErrNotEditable):
+		res.WriteHeader(http.StatusConflict)
+	default:
+		log.Error().Str("language_tag", tag.String()).Err(err).Msg("Unexpected error updating strings")
+		res.WriteHeader(http.StatusInternalServerError)
+		res.Write([]byte("500 - Unexpected error"))
+		return
+	}
+
+	log.Debug().Str("language_tag", tag.String()).Err(err).Msg("Rejected update")
+	res.Write([]byte(err.Error()))
+}
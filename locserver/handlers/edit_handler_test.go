@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+)
+
+func newEditRouter(st *loader.StringTable) *mux.Router {
+	router := mux.NewRouter()
+	router.Handle("/v1/strings/{str}", EditStringHandler{ST: st}).Methods(http.MethodPut, http.MethodDelete)
+	router.Handle("/v1/strings", EditStringsHandler{ST: st}).Methods(http.MethodPatch)
+	return router
+}
+
+func TestEditHandlers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edit-handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "es-mx"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "es-mx", "messages.json"), gotextFile("es-mx", "hello", "hola", "bye", "adios").Data, 0644)
+
+	st, err := loader.NewStringTable(dir, false, loader.NewGoTextJSONLoader())
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+	router := newEditRouter(st)
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return res
+	}
+
+	res := send(http.MethodPut, "/v1/strings/hello?lang=es-mx", "¡hola!")
+	assert.Equal(t, http.StatusNoContent, res.Code)
+
+	res = send(http.MethodPatch, "/v1/strings?lang=es-mx", `{"bye": null, "thanks": "gracias"}`)
+	assert.Equal(t, http.StatusNoContent, res.Code)
+
+	cat, err := st.StringsByTag(language.MustParse("es-mx"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"hello": "¡hola!", "thanks": "gracias"}, cat.Strings)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "es-mx", "messages.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"translation": "gracias"`)
+
+	res = send(http.MethodDelete, "/v1/strings/thanks?lang=es-mx", "")
+	assert.Equal(t, http.StatusNoContent, res.Code)
+
+	res = send(http.MethodDelete, "/v1/strings/thanks?lang=es-mx", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	res = send(http.MethodPut, "/v1/strings/hello?lang=pt-br", "olá")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	res = send(http.MethodPut, "/v1/strings/hello", "hola")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = send(http.MethodPatch, "/v1/strings?lang=es-mx", `["not", "an", "object"]`)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestEditHandlersReadOnly(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello"),
+	})
+	router := newEditRouter(st)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/v1/strings/hello?lang=en-us", strings.NewReader("Hi")))
+	assert.Equal(t, http.StatusForbidden, res.Code)
+}
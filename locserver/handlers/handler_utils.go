@@ -5,12 +5,19 @@ import (
 	"strings"
 
 	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
 )
 
 // DefaultContentType is the output content type if no other is found on the request
 const DefaultContentType = "text/csv"
 
-var supportedContentTypes = map[string]bool{"text/csv": true, "application/json": true}
+// ESMContentType is the content type of strings served as a JavaScript module.
+const ESMContentType = "text/javascript"
+
+var supportedContentTypes = map[string]bool{"text/csv": true, "application/json": true, ESMContentType: true}
+
+// formatNames are the fmt query param names of the content types that aren't written by a loader.Writer.
+var formatNames = map[string]string{"esm": ESMContentType}
 
 // GetQueryParam gets the first (if any) value from the query string with the given param name.
 func GetQueryParam(req *http.Request, paramName string) string {
@@ -42,7 +49,17 @@ func ExtractContentType(req *http.Request) (contentType string) {
 	if len(fmtParam) > 0 {
 		log.Debug().
 			Msg("Returning format from query param")
-		return fmtParam
+		if t, ok := formatNames[fmtParam]; ok {
+			return t
+		}
+		if f, ok := loader.LookupWriter(fmtParam); ok {
+			return f.ContentType
+		}
+		if isSupportedContentType(fmtParam) {
+			return fmtParam
+		}
+		// Unknown formats get the default, as with an unsupported Accept header.
+		return DefaultContentType
 	}
 
 	rawHeader := req.Header.Get("Accept")
@@ -63,10 +80,28 @@ func ExtractContentType(req *http.Request) (contentType string) {
 			t = t[0:semiPos]
 		}
 		cleanType := strings.TrimSpace(t)
-		if _, ok := supportedContentTypes[cleanType]; ok {
+		if isSupportedContentType(cleanType) {
 			contentType = cleanType
 			return
 		}
 	}
 	return
 }
+
+// isSupportedContentType checks whether strings can be written in a content type, either natively or by a loader.Writer.
+func isSupportedContentType(contentType string) bool {
+	if supportedContentTypes[contentType] {
+		return true
+	}
+	_, ok := writerForContentType(contentType)
+	return ok
+}
+
+func writerForContentType(contentType string) (loader.WriterFormat, bool) {
+	for _, f := range loader.Writers() {
+		if f.ContentType == contentType {
+			return f, true
+		}
+	}
+	return loader.WriterFormat{}, false
+}
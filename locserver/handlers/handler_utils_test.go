@@ -89,3 +89,24 @@ func TestExtractContentType_IgnorePriority(t *testing.T) {
 	contentType := ExtractContentType(req)
 	assert.Equal(t, "application/json", contentType)
 }
+
+func TestExtractContentType_WriterName(t *testing.T) {
+	req := &http.Request{
+		URL:    &url.URL{RawQuery: "fmt=xliff2"},
+		Header: http.Header{},
+	}
+
+	contentType := ExtractContentType(req)
+	assert.Equal(t, "application/xliff+xml", contentType)
+}
+
+func TestExtractContentType_WriterFromHeader(t *testing.T) {
+	req := &http.Request{
+		URL:    &url.URL{},
+		Header: http.Header{},
+	}
+	req.Header.Add("Accept", "text/html,application/x-arb+json")
+
+	contentType := ExtractContentType(req)
+	assert.Equal(t, "application/x-arb+json", contentType)
+}
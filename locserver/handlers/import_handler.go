@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/language"
+)
+
+// ImportHandler handles the upload of a locale file as the "file" field of a multipart form.
+// The file is read by the loader that matches it, other than the exec loader, and compared with the
+// strings served in the language given by the lang query param. Nothing changes unless confirm is true,
+// in which case the locale files are updated to match.
+type ImportHandler struct {
+	ST *loader.StringTable
+}
+
+type changedString struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type importDiff struct {
+	Language string                   `json:"language"`
+	Added    map[string]string        `json:"added"`
+	Changed  map[string]changedString `json:"changed"`
+	Removed  []string                 `json:"removed"`
+	Applied  bool                     `json:"applied"`
+}
+
+func (h ImportHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	tag, ok := editTag(res, req)
+	if !ok {
+		return
+	}
+
+	req.Body = http.MaxBytesReader(res, req.Body, maxEditSize)
+	file, header, err := req.FormFile("file")
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - A multipart form with a file field is required"))
+		return
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - Unable to read file"))
+		return
+	}
+	confirm, _ := strconv.ParseBool(req.FormValue("confirm"))
+
+	cats, err := loader.ReadUploadedCatalogs(header.Filename, data, &tag)
+	if errors.Is(err, loader.ErrUnknownFormat) {
+		res.WriteHeader(http.StatusUnsupportedMediaType)
+		res.Write([]byte("415 - Unknown locale file format"))
+		return
+	}
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - Unable to read file: " + err.Error()))
+		return
+	}
+	uploaded, ok := cats[tag]
+	if !ok {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - File has no strings for " + tag.String()))
+		return
+	}
+
+	loaded := false
+	for _, t := range h.ST.Tags() {
+		loaded = loaded || t == tag
+	}
+	current := map[string]string{}
+	if cat, err := h.ST.StringsByTag(tag); err == nil {
+		current = cat.Strings
+	}
+	diff := diffStrings(tag, current, uploaded.Strings)
+
+	if confirm {
+		if loaded {
+			err = h.ST.Update(tag, diff.edits())
+		} else {
+			err = h.ST.AddLocale(tag, path.Base(header.Filename), data)
+		}
+		if err != nil {
+			writeUpdate(res, tag, err)
+			return
+		}
+		diff.Applied = true
+		log.Info().Str("language_tag", tag.String()).Str("file", header.Filename).
+			Int("added", len(diff.Added)).Int("changed", len(diff.Changed)).Int("removed", len(diff.Removed)).
+			Msg("Imported strings")
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(res).Encode(diff)
+	if err != nil {
+		log.Error().Err(err).Msg("Unexpected error writing import diff")
+	}
+}
+
+// diffStrings finds the changes that would turn the current strings into the uploaded ones.
+func diffStrings(tag language.Tag, current, uploaded map[string]string) importDiff {
+	diff := importDiff{
+		Language: tag.String(),
+		Added:    map[string]string{},
+		Changed:  map[string]changedString{},
+		Removed:  []string{},
+	}
+	for k, v := range uploaded {
+		if old, ok := current[k]; !ok {
+			diff.Added[k] = v
+		} else if old != v {
+			diff.Changed[k] = changedString{From: old, To: v}
+		}
+	}
+	for k := range current {
+		if _, ok := uploaded[k]; !ok {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// edits gets the changes in the diff, in a stable order.
+func (diff importDiff) edits() []loader.Edit {
+	keys := []string{}
+	for k := range diff.Added {
+		keys = append(keys, k)
+	}
+	for k := range diff.Changed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	edits := []loader.Edit{}
+	for _, k := range keys {
+		if v, ok := diff.Added[k]; ok {
+			edits = append(edits, loader.Edit{Key: k, Value: v})
+		} else {
+			edits = append(edits, loader.Edit{Key: k, Value: diff.Changed[k].To})
+		}
+	}
+	for _, k := range diff.Removed {
+		edits = append(edits, loader.Edit{Key: k, Delete: true})
+	}
+	return edits
+}
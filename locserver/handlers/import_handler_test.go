@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+)
+
+func importRequest(target, filename string, data []byte) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", filename)
+	part.Write(data)
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestImportHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import-handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "it-it"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "it-it", "messages.json"),
+		gotextFile("it-it", "hello", "ciao", "bye", "arrivederci", "old", "vecchio").Data, 0644)
+
+	st, err := loader.NewStringTable(dir, false, loader.NewAutoLoader())
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+	h := ImportHandler{ST: st}
+
+	upload := gotextFile("it-it", "hello", "salve", "bye", "arrivederci", "new", "nuovo").Data
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, importRequest("/v1/import?lang=it-it", "it.json", upload))
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var diff importDiff
+	err = json.NewDecoder(res.Body).Decode(&diff)
+	assert.Nil(t, err)
+	assert.Equal(t, importDiff{
+		Language: "it-IT",
+		Added:    map[string]string{"new": "nuovo"},
+		Changed:  map[string]changedString{"hello": {From: "ciao", To: "salve"}},
+		Removed:  []string{"old"},
+	}, diff)
+
+	// A dry run changes nothing, including what printers see.
+	it := language.MustParse("it-it")
+	cat, _ := st.StringsByTag(it)
+	assert.Equal(t, "ciao", cat.Strings["hello"])
+	assert.Equal(t, "ciao", message.NewPrinter(it).Sprintf("hello"))
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, importRequest("/v1/import?lang=it-it&confirm=true", "it.json", upload))
+	assert.Equal(t, http.StatusOK, res.Code)
+	diff = importDiff{}
+	json.NewDecoder(res.Body).Decode(&diff)
+	assert.True(t, diff.Applied)
+
+	cat, _ = st.StringsByTag(it)
+	assert.Equal(t, map[string]string{"hello": "salve", "bye": "arrivederci", "new": "nuovo"}, cat.Strings)
+
+	// A new language is saved as it is.
+	po := []byte("msgid \"hello\"\nmsgstr \"hallo\"\n")
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, importRequest("/v1/import?lang=de-at&confirm=true", "de.po", po))
+	assert.Equal(t, http.StatusOK, res.Code)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "de-at", "de.po"))
+	assert.Nil(t, err)
+	assert.Equal(t, po, data)
+	tag, _ := st.MatchStrings("de-at")
+	assert.Equal(t, "de-AT", tag.String())
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, importRequest("/v1/import?lang=it-it", "notes.txt", []byte("hello")))
+	assert.Equal(t, http.StatusUnsupportedMediaType, res.Code)
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, importRequest("/v1/import?lang=fr-fr", "it.json", upload))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Shapes of the JSON output of a string catalog, picked with the shape query param.
+const (
+	// ShapeList is an array of objects with an id and a translation, the original JSON output.
+	ShapeList = "list"
+	// ShapeFlat is an object of ids to translations.
+	ShapeFlat = "flat"
+	// ShapeNested is an object of ids split on a separator into nested objects, as frontend i18n libraries expect.
+	ShapeNested = "nested"
+)
+
+// DefaultKeySeparator splits ids into nested objects if no other is given by the sep query param.
+const DefaultKeySeparator = "."
+
+var supportedShapes = map[string]bool{ShapeList: true, ShapeFlat: true, ShapeNested: true}
+
+// KeyConflictError reports ids that can't be nested because another id nests inside them.
+type KeyConflictError struct {
+	Keys []string
+}
+
+func (e *KeyConflictError) Error() string {
+	return fmt.Sprintf("ids are both a string and a prefix of other ids: %s", strings.Join(e.Keys, ", "))
+}
+
+// nestStrings splits each id on sep and nests its translation in objects by the parts.
+func nestStrings(strs map[string]string, sep string) (map[string]interface{}, error) {
+	nested := map[string]interface{}{}
+	conflicts := map[string]bool{}
+	for _, k := range sortedKeys(strs) {
+		parts := strings.Split(k, sep)
+		obj := nested
+		for i, part := range parts[:len(parts)-1] {
+			next, ok := obj[part]
+			if !ok {
+				next = map[string]interface{}{}
+				obj[part] = next
+			}
+			child, ok := next.(map[string]interface{})
+			if !ok {
+				conflicts[strings.Join(parts[:i+1], sep)] = true
+				obj = nil
+				break
+			}
+			obj = child
+		}
+		if obj == nil {
+			continue
+		}
+
+		last := parts[len(parts)-1]
+		if _, ok := obj[last]; ok {
+			conflicts[k] = true
+			continue
+		}
+		obj[last] = strs[k]
+	}
+
+	if len(conflicts) > 0 {
+		err := &KeyConflictError{}
+		for k := range conflicts {
+			err.Keys = append(err.Keys, k)
+		}
+		sort.Strings(err.Keys)
+		return nil, err
+	}
+	return nested, nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNestStrings(t *testing.T) {
+	nested, err := nestStrings(map[string]string{
+		"users.errors.not_found": "User not found",
+		"users.errors.locked":    "Account locked",
+		"users.title":            "Users",
+		"home":                   "Home",
+	}, ".")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"home": "Home",
+		"users": map[string]interface{}{
+			"title": "Users",
+			"errors": map[string]interface{}{
+				"not_found": "User not found",
+				"locked":    "Account locked",
+			},
+		},
+	}, nested)
+}
+
+func TestNestStrings_CustomSeparator(t *testing.T) {
+	nested, err := nestStrings(map[string]string{"users/title": "Users", "users.count": "Count"}, "/")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"users":       map[string]interface{}{"title": "Users"},
+		"users.count": "Count",
+	}, nested)
+}
+
+func TestNestStrings_Conflict(t *testing.T) {
+	_, err := nestStrings(map[string]string{
+		"users":         "Users",
+		"users.title":   "Users",
+		"home.title":    "Home",
+		"home.title.sm": "Home",
+		"about":         "About",
+	}, ".")
+	assert.Equal(t, &KeyConflictError{Keys: []string{"home.title", "users"}}, err)
+}
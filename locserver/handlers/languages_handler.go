@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// LanguagesHandler handles a request for the loaded languages, with how complete each is compared to the source language.
+type LanguagesHandler struct {
+	ST         *loader.StringTable
+	SourceLang string
+}
+
+type languageInfo struct {
+	Tag          string    `json:"tag"`
+	Name         string    `json:"name"`
+	SelfName     string    `json:"self_name"`
+	Script       string    `json:"script"`
+	Direction    string    `json:"direction"`
+	Keys         int       `json:"keys"`
+	Completeness *float64  `json:"completeness,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type languages struct {
+	Source    string         `json:"source,omitempty"`
+	Languages []languageInfo `json:"languages"`
+}
+
+// rtlScripts are the scripts written right to left.
+var rtlScripts = map[string]bool{
+	"Adlm": true, "Arab": true, "Hebr": true, "Mand": true, "Nkoo": true,
+	"Rohg": true, "Samr": true, "Syrc": true, "Thaa": true, "Yezi": true,
+}
+
+func (h LanguagesHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	data := languages{Languages: []languageInfo{}}
+
+	var source map[string]string
+	if tag, ok := sourceTag(h.ST, h.SourceLang); ok {
+		if cat, err := h.ST.StringsByTag(tag); err == nil {
+			data.Source = tag.String()
+			source = cat.Strings
+		}
+	}
+
+	english := display.English.Tags()
+	for _, tag := range h.ST.Tags() {
+		cat, err := h.ST.StringsByTag(tag)
+		if err != nil {
+			log.Warn().Str("language_tag", tag.String()).Err(err).Msg("Getting strings for language")
+			continue
+		}
+
+		script, _ := tag.Script()
+		info := languageInfo{
+			Tag:          tag.String(),
+			Name:         english.Name(tag),
+			SelfName:     selfName(tag),
+			Script:       script.String(),
+			Direction:    "ltr",
+			Keys:         len(cat.Strings),
+			LastModified: cat.LastModTime,
+		}
+		if rtlScripts[script.String()] {
+			info.Direction = "rtl"
+		}
+		if len(source) > 0 {
+			translated := 0
+			for k := range source {
+				if _, ok := cat.Strings[k]; ok {
+					translated++
+				}
+			}
+			completeness := float64(translated) / float64(len(source))
+			info.Completeness = &completeness
+		}
+		data.Languages = append(data.Languages, info)
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(res).Encode(data)
+	if err != nil {
+		log.Error().Err(err).Msg("Unexpected error writing languages")
+	}
+}
+
+// sourceTag finds the loaded language for a source language, preferring an exact match to one with the same
+// base language.
+func sourceTag(st *loader.StringTable, lang string) (language.Tag, bool) {
+	want, err := language.Parse(lang)
+	if err != nil {
+		return language.Und, false
+	}
+	wantBase, _ := want.Base()
+	var found language.Tag
+	ok := false
+	for _, tag := range st.Tags() {
+		if tag == want {
+			return tag, true
+		}
+		if base, _ := tag.Base(); base == wantBase && !ok {
+			found, ok = tag, true
+		}
+	}
+	return found, ok
+}
+
+// selfName gets the name of a language in itself, with its region if the language has names for regions.
+func selfName(tag language.Tag) string {
+	if namer := display.Tags(tag); namer != nil {
+		if name := namer.Name(tag); name != "" {
+			return name
+		}
+	}
+	return display.Self.Name(tag)
+}
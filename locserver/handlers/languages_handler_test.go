@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguagesHandler(t *testing.T) {
+	modTime := time.Date(2022, 5, 6, 7, 8, 9, 0, time.UTC)
+	ar := gotextFile("ar-eg", "hello", "مرحبا")
+	ar.ModTime = modTime
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello", "bye", "Bye", "thanks", "Thanks", "yes", "Yes"),
+		"ar-eg/messages.json": ar,
+		"de-de/messages.json": gotextFile("de-de", "hello", "Hallo", "bye", "Tschüss", "extra", "Extra"),
+	})
+
+	res := httptest.NewRecorder()
+	LanguagesHandler{ST: st, SourceLang: "en"}.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/languages", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var body languages
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, "en-US", body.Source)
+	byTag := map[string]languageInfo{}
+	for _, l := range body.Languages {
+		byTag[l.Tag] = l
+	}
+	assert.Len(t, byTag, 3)
+
+	arEG := byTag["ar-EG"]
+	assert.Equal(t, "Arabic (Egypt)", arEG.Name)
+	assert.Equal(t, "العربية (مصر)", arEG.SelfName)
+	assert.Equal(t, "Arab", arEG.Script)
+	assert.Equal(t, "rtl", arEG.Direction)
+	assert.Equal(t, 1, arEG.Keys)
+	assert.Equal(t, 0.25, *arEG.Completeness)
+	assert.True(t, modTime.Equal(arEG.LastModified))
+
+	deDE := byTag["de-DE"]
+	assert.Equal(t, "Deutsch (Deutschland)", deDE.SelfName)
+	assert.Equal(t, "Latn", deDE.Script)
+	assert.Equal(t, "ltr", deDE.Direction)
+	assert.Equal(t, 3, deDE.Keys)
+	assert.Equal(t, 0.5, *deDE.Completeness)
+
+	assert.Equal(t, 1.0, *byTag["en-US"].Completeness)
+}
+
+func TestLanguagesHandlerWithoutSource(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour")})
+
+	res := httptest.NewRecorder()
+	LanguagesHandler{ST: st, SourceLang: "en-us"}.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/languages", nil))
+
+	var body languages
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Empty(t, body.Source)
+	assert.Nil(t, body.Languages[0].Completeness)
+	assert.Equal(t, "French (France)", body.Languages[0].Name)
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/language"
+)
+
+const (
+	// maxMissingKeys bounds MissingKeys, since keys come from clients.
+	maxMissingKeys = 10000
+	// maxMissingReferrers is how many distinct referrers are kept as samples for each missing key.
+	maxMissingReferrers = 5
+)
+
+// MissingKeys records lookups of keys that a language has no translation of.
+type MissingKeys struct {
+	mu      sync.Mutex
+	entries map[missingKeyID]*MissingKey
+	now     func() time.Time
+}
+
+type missingKeyID struct {
+	key  string
+	lang string
+}
+
+// MissingKey is what is known about the lookups of a key missing from a language.
+type MissingKey struct {
+	Key      string `json:"key"`
+	Language string `json:"language"`
+	// Count is the number of lookups, including the Reported ones.
+	Count int64 `json:"count"`
+	// Reported is the number of lookups clients reported, rather than made of the server.
+	Reported  int64     `json:"reported"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Referrers []string  `json:"referrers"`
+}
+
+// NewMissingKeys is the factory method for MissingKeys.
+func NewMissingKeys() *MissingKeys {
+	return &MissingKeys{
+		entries: map[missingKeyID]*MissingKey{},
+		now:     time.Now,
+	}
+}
+
+// Record counts a lookup of a key missing from a language, the loaded one negotiated for the lookup.
+// It is safe to call on a nil MissingKeys.
+func (m *MissingKeys) Record(key string, tag language.Tag, referrer string, reported bool) {
+	if m == nil {
+		return
+	}
+	now := m.now()
+	id := missingKeyID{key: key, lang: tag.String()}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[id]
+	if !ok {
+		if len(m.entries) >= maxMissingKeys {
+			return
+		}
+		entry = &MissingKey{Key: key, Language: id.lang, FirstSeen: now, Referrers: []string{}}
+		m.entries[id] = entry
+	}
+	entry.Count++
+	if reported {
+		entry.Reported++
+	}
+	entry.LastSeen = now
+	if referrer != "" && len(entry.Referrers) < maxMissingReferrers && !containsString(entry.Referrers, referrer) {
+		entry.Referrers = append(entry.Referrers, referrer)
+	}
+}
+
+// List gets copies of the missing keys, most looked up first.
+func (m *MissingKeys) List() []MissingKey {
+	m.mu.Lock()
+	list := make([]MissingKey, 0, len(m.entries))
+	for _, e := range m.entries {
+		c := *e
+		c.Referrers = append([]string{}, e.Referrers...)
+		list = append(list, c)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		if list[i].Language != list[j].Language {
+			return list[i].Language < list[j].Language
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingHandler handles requests for the keys looked up without a translation (GET), and reports of them
+// from clients (POST). Keys translated since they were missing are left out.
+type MissingHandler struct {
+	ST      *loader.StringTable
+	Missing *MissingKeys
+	// Negotiator matches request languages to the loaded ones, with the defaults if nil.
+	Negotiator *Negotiator
+}
+
+type missingList struct {
+	Missing []MissingKey `json:"missing"`
+}
+
+// missingReport is a POST of keys a client found missing, in a language or else the negotiated one.
+type missingReport struct {
+	Language string   `json:"language,omitempty"`
+	Keys     []string `json:"keys"`
+}
+
+type missingReportResult struct {
+	Language string `json:"language"`
+	Recorded int    `json:"recorded"`
+}
+
+func (h MissingHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		h.report(res, req)
+		return
+	}
+
+	// Filter by the language param rather than lang, which would negotiate one.
+	var only *language.Tag
+	if l := req.URL.Query().Get("language"); l != "" {
+		tag, err := language.Parse(l)
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("400 - Unknown language"))
+			return
+		}
+		only = &tag
+	}
+
+	data := missingList{Missing: []MissingKey{}}
+	catalogs := map[string]*loader.StringCatalog{}
+	for _, m := range h.Missing.List() {
+		if only != nil && m.Language != only.String() {
+			continue
+		}
+		cat, ok := catalogs[m.Language]
+		if !ok {
+			cat, _ = h.ST.StringsByTag(language.Make(m.Language))
+			catalogs[m.Language] = cat
+		}
+		if cat != nil {
+			if _, translated := cat.Strings[m.Key]; translated {
+				continue
+			}
+		}
+		data.Missing = append(data.Missing, m)
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	err := json.NewEncoder(res).Encode(data)
+	if err != nil {
+		log.Error().Err(err).Msg("Unexpected error writing missing keys")
+	}
+}
+
+// report records the keys a client found missing that the server has no translation of either.
+func (h MissingHandler) report(res http.ResponseWriter, req *http.Request) {
+	var report missingReport
+	err := json.NewDecoder(http.MaxBytesReader(res, req.Body, maxEditSize)).Decode(&report)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - Body must be a JSON object with a list of keys"))
+		return
+	}
+	if len(report.Keys) > maxBatchKeys {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - At most " + strconv.Itoa(maxBatchKeys) + " keys can be reported at once"))
+		return
+	}
+
+	var tag language.Tag
+	if report.Language != "" {
+		desired, err := language.Parse(report.Language)
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("400 - Unknown language"))
+			return
+		}
+		tag, _, _ = h.ST.Match(h.Negotiator.resolve(desired))
+	} else {
+		tag = h.Negotiator.negotiate(h.ST, req).Tag
+	}
+
+	cat, err := h.ST.StringsByTag(tag)
+	if err != nil {
+		cat = loader.NewStringCatalog(h.ST.LoadedAt())
+	}
+	result := missingReportResult{Language: tag.String()}
+	for _, k := range report.Keys {
+		if _, ok := cat.Strings[k]; ok {
+			continue
+		}
+		h.Missing.Record(k, tag, req.Referer(), true)
+		result.Recorded++
+	}
+	log.Debug().
+		Str("language_tag", tag.String()).
+		Int("keys", len(report.Keys)).
+		Int("recorded", result.Recorded).
+		Msg("Recorded missing keys reported by a client")
+
+	res.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(res).Encode(result)
+	if err != nil {
+		log.Error().Err(err).Msg("Unexpected error writing missing key report")
+	}
+}
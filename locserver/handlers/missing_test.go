@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestMissingKeysRecord(t *testing.T) {
+	m := NewMissingKeys()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	fr := language.MustParse("fr-FR")
+	m.Record("goodbye", fr, "https://example.com/a", false)
+	now = now.Add(time.Minute)
+	m.Record("goodbye", fr, "https://example.com/b", true)
+	m.Record("goodbye", fr, "https://example.com/a", false)
+	m.Record("title", fr, "", false)
+
+	list := m.List()
+	assert.Len(t, list, 2)
+	assert.Equal(t, MissingKey{
+		Key:       "goodbye",
+		Language:  "fr-FR",
+		Count:     3,
+		Reported:  1,
+		FirstSeen: now.Add(-time.Minute),
+		LastSeen:  now,
+		Referrers: []string{"https://example.com/a", "https://example.com/b"},
+	}, list[0])
+	assert.Equal(t, "title", list[1].Key)
+	assert.Empty(t, list[1].Referrers)
+
+	var nilKeys *MissingKeys
+	nilKeys.Record("goodbye", fr, "", false)
+}
+
+func TestMissingHandler(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello", "goodbye", "Goodbye"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour"),
+	})
+	missing := NewMissingKeys()
+
+	// Lookups of single strings and batches are recorded.
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/strings/goodbye?lang=fr-ca", nil)
+	req.Header.Set("Referer", "https://example.com/checkout")
+	StringHandler{ST: st, Missing: missing}.ServeHTTP(res, mux.SetURLVars(req, map[string]string{"str": "goodbye"}))
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/v1/strings:batch?lang=fr-fr", strings.NewReader(`{"keys": ["hello", "goodbye", "title"]}`))
+	BatchHandler{ST: st, Missing: missing}.ServeHTTP(res, req)
+
+	// Clients can report misses, which count only if the server is missing them too.
+	h := MissingHandler{ST: st, Missing: missing}
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/v1/missing", strings.NewReader(`{"language": "fr", "keys": ["hello", "title"]}`)))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"language": "fr-FR", "recorded": 1}`, res.Body.String())
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/missing", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	var body missingList
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Len(t, body.Missing, 2)
+	// Equal counts are in order of language then key.
+	assert.Equal(t, "goodbye", body.Missing[0].Key)
+	assert.Equal(t, "fr-FR", body.Missing[0].Language)
+	assert.Equal(t, int64(2), body.Missing[0].Count)
+	assert.Equal(t, []string{"https://example.com/checkout"}, body.Missing[0].Referrers)
+	assert.Equal(t, "title", body.Missing[1].Key)
+	assert.Equal(t, int64(2), body.Missing[1].Count)
+	assert.Equal(t, int64(1), body.Missing[1].Reported)
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/missing?language=en-us", nil))
+	assert.JSONEq(t, `{"missing": []}`, res.Body.String())
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/v1/missing", strings.NewReader(`{"keys": "title"}`)))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/language"
+)
+
+// Places in a request that a language preference comes from.
+const (
+	// LanguageFromQuery is the lang query param.
+	LanguageFromQuery = "query"
+	// LanguageFromCookie is the lang cookie.
+	LanguageFromCookie = "cookie"
+	// LanguageFromHeader is the Accept-Language header.
+	LanguageFromHeader = "header"
+)
+
+// DefaultLanguagePrecedence is the order language preferences are considered in, unless a Negotiator changes it.
+var DefaultLanguagePrecedence = []string{LanguageFromQuery, LanguageFromCookie, LanguageFromHeader}
+
+// Negotiator matches the language preferences of requests to the loaded languages.
+// A nil Negotiator uses DefaultLanguagePrecedence, no aliases, and the first loaded language as the default.
+type Negotiator struct {
+	precedence []string
+	// aliases map canonical tags to the tags to match instead.
+	aliases map[language.Tag]language.Tag
+	// defaultLanguage is used when nothing matches, if set; otherwise the first loaded language is.
+	defaultLanguage *language.Tag
+}
+
+// NewNegotiator is the factory method for Negotiator.
+// The precedence is the order that the places in a request are considered in; places left out are ignored.
+// The aliases are tags to match in place of others, such as zh=zh-Hans-CN. Both sides are canonicalized,
+// so an alias applies to any way of writing its tag. The default language, if not empty, is used when a
+// request matches none of the loaded languages.
+func NewNegotiator(precedence []string, aliases map[string]string, defaultLang string) (*Negotiator, error) {
+	neg := &Negotiator{aliases: map[language.Tag]language.Tag{}}
+
+	seen := map[string]bool{}
+	for _, o := range precedence {
+		switch o {
+		case LanguageFromQuery, LanguageFromCookie, LanguageFromHeader:
+		default:
+			return nil, fmt.Errorf("unknown language source %q", o)
+		}
+		if seen[o] {
+			return nil, fmt.Errorf("language source %q given twice", o)
+		}
+		seen[o] = true
+	}
+	if len(precedence) == 0 {
+		return nil, fmt.Errorf("no language sources given")
+	}
+	neg.precedence = append([]string{}, precedence...)
+
+	for from, to := range aliases {
+		fromTag, err := language.Parse(from)
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %v", from, err)
+		}
+		toTag, err := language.Parse(to)
+		if err != nil {
+			return nil, fmt.Errorf("alias %q for %q: %v", to, from, err)
+		}
+		key := canonicalLanguage(fromTag)
+		if _, ok := neg.aliases[key]; ok {
+			return nil, fmt.Errorf("alias %q: %s has more than one alias", from, key)
+		}
+		neg.aliases[key] = canonicalLanguage(toTag)
+	}
+
+	if defaultLang != "" {
+		tag, err := language.Parse(defaultLang)
+		if err != nil {
+			return nil, fmt.Errorf("default language %q: %v", defaultLang, err)
+		}
+		neg.defaultLanguage = &tag
+	}
+	return neg, nil
+}
+
+// Precedence gets the order that the places in a request are considered in when negotiating a language.
+func (neg *Negotiator) Precedence() []string {
+	if neg == nil {
+		return append([]string{}, DefaultLanguagePrecedence...)
+	}
+	return append([]string{}, neg.precedence...)
+}
+
+// CheckDefault checks that the default language, if set, is one of the loaded languages.
+func (neg *Negotiator) CheckDefault(st *loader.StringTable) error {
+	if neg == nil || neg.defaultLanguage == nil {
+		return nil
+	}
+	if _, _, c := st.Match(*neg.defaultLanguage); c != language.Exact {
+		return fmt.Errorf("default language %s is not loaded", neg.defaultLanguage)
+	}
+	return nil
+}
+
+// canonicalLanguage replaces deprecated and legacy subtags, such as iw with he or tl with fil. It leaves
+// individual languages alone rather than replace them with their macrolanguage, so nb-NO stays nb-NO.
+// Underscore forms such as zh_CN are already accepted by parsing.
+func canonicalLanguage(tag language.Tag) language.Tag {
+	if c, err := (language.Deprecated | language.Legacy | language.CLDR).Canonicalize(tag); err == nil {
+		return c
+	}
+	return tag
+}
+
+// resolve canonicalizes a desired language and applies its alias, if any.
+func (neg *Negotiator) resolve(tag language.Tag) language.Tag {
+	tag = canonicalLanguage(tag)
+	if neg == nil {
+		return tag
+	}
+	if alias, ok := neg.aliases[tag]; ok {
+		return alias
+	}
+	return tag
+}
+
+// languageInput is a language preference from one place in a request.
+type languageInput struct {
+	Source string `json:"source"`
+	Value  string `json:"value"`
+}
+
+// languageCandidate is a desired language and how well it alone matches the loaded languages.
+type languageCandidate struct {
+	Source    string `json:"source"`
+	Requested string `json:"requested,omitempty"`
+	// Resolved is the language matched for Requested, if canonicalization or an alias changed it.
+	Resolved   string `json:"resolved,omitempty"`
+	Matched    string `json:"matched,omitempty"`
+	Confidence string `json:"confidence"`
+	Chosen     bool   `json:"chosen"`
+	// Error is why the requested value couldn't be parsed.
+	Error string `json:"error,omitempty"`
+}
+
+// negotiation is the language matched for a request, and why.
+type negotiation struct {
+	Inputs     []languageInput `json:"inputs"`
+	Tag        language.Tag    `json:"-"`
+	Matched    string          `json:"matched"`
+	Confidence string          `json:"confidence"`
+	// Path is each desired language in order, then the default language if none matched:
+	// the Negotiator's, or else the first loaded language.
+	Path []languageCandidate `json:"path"`
+}
+
+// negotiate matches the language preferences of a request, in the configured precedence, to the loaded languages.
+func (neg *Negotiator) negotiate(st *loader.StringTable, req *http.Request) negotiation {
+	lang, accept, param := ExtractLang(req)
+	values := map[string]string{LanguageFromQuery: param, LanguageFromCookie: lang, LanguageFromHeader: accept}
+
+	n := negotiation{Inputs: []languageInput{}, Path: []languageCandidate{}}
+	desired := []language.Tag{}
+	for _, source := range neg.Precedence() {
+		value := values[source]
+		if value == "" {
+			continue
+		}
+		n.Inputs = append(n.Inputs, languageInput{Source: source, Value: value})
+		// Like language.MatchStrings, each value may be a list of languages, and unparseable ones are skipped.
+		tags, _, err := language.ParseAcceptLanguage(value)
+		if err != nil {
+			n.Path = append(n.Path, languageCandidate{Source: source, Requested: value, Confidence: language.No.String(), Error: err.Error()})
+			continue
+		}
+		for _, d := range tags {
+			r := neg.resolve(d)
+			t, _, c := st.Match(r)
+			candidate := languageCandidate{Source: source, Requested: d.String(), Matched: t.String(), Confidence: c.String()}
+			if r != d {
+				candidate.Resolved = r.String()
+			}
+			n.Path = append(n.Path, candidate)
+			desired = append(desired, r)
+		}
+	}
+
+	tag, _, confidence := st.Match(desired...)
+	if confidence == language.No {
+		if neg != nil && neg.defaultLanguage != nil {
+			tag, _, _ = st.Match(*neg.defaultLanguage)
+		}
+	}
+	n.Tag = tag
+	n.Matched = tag.String()
+	n.Confidence = confidence.String()
+
+	if confidence != language.No {
+		for i, c := range n.Path {
+			if c.Error == "" && c.Matched == n.Matched && c.Confidence == n.Confidence {
+				n.Path[i].Chosen = true
+				return n
+			}
+		}
+	}
+	n.Path = append(n.Path, languageCandidate{
+		Source:     "default",
+		Matched:    n.Matched,
+		Confidence: n.Confidence,
+		Chosen:     true,
+	})
+	return n
+}
+
+// NegotiateHandler handles a request to explain which language the other handlers would choose for it.
+type NegotiateHandler struct {
+	ST *loader.StringTable
+	// Negotiator matches request languages to the loaded ones, with the defaults if nil.
+	Negotiator *Negotiator
+}
+
+func (h NegotiateHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(res).Encode(h.Negotiator.negotiate(h.ST, req))
+	if err != nil {
+		log.Error().Err(err).Msg("Unexpected error writing negotiation")
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newNegotiationRequest(query, cookie, accept string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/v1/negotiate?"+query, nil)
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: "lang", Value: cookie})
+	}
+	if accept != "" {
+		req.Header.Set("Accept-Language", accept)
+	}
+	return req
+}
+
+func TestNegotiateHandler(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour"),
+		"de-de/messages.json": gotextFile("de-de", "hello", "Hallo"),
+	})
+
+	res := httptest.NewRecorder()
+	NegotiateHandler{ST: st}.ServeHTTP(res, newNegotiationRequest("lang=xx", "fr-ca", "de;q=0.9"))
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var body negotiation
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, []languageInput{
+		{Source: LanguageFromQuery, Value: "xx"},
+		{Source: LanguageFromCookie, Value: "fr-ca"},
+		{Source: LanguageFromHeader, Value: "de;q=0.9"},
+	}, body.Inputs)
+	assert.Equal(t, "fr-FR", body.Matched)
+	assert.Equal(t, "High", body.Confidence)
+	assert.Len(t, body.Path, 3)
+	assert.Equal(t, LanguageFromQuery, body.Path[0].Source)
+	assert.Equal(t, "No", body.Path[0].Confidence)
+	assert.NotEmpty(t, body.Path[0].Error)
+	assert.False(t, body.Path[0].Chosen)
+	assert.Equal(t, "fr-CA", body.Path[1].Requested)
+	assert.Equal(t, "fr-FR", body.Path[1].Matched)
+	assert.True(t, body.Path[1].Chosen)
+	assert.False(t, body.Path[2].Chosen)
+
+	res = httptest.NewRecorder()
+	NegotiateHandler{ST: st}.ServeHTTP(res, newNegotiationRequest("lang=xx", "", ""))
+	body = negotiation{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, "No", body.Confidence)
+	assert.Equal(t, languageCandidate{Source: "default", Matched: body.Matched, Confidence: "No", Chosen: true}, body.Path[1])
+}
+
+func TestNegotiateLoadedLanguage(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour"),
+	})
+
+	// Matches carry no extensions, whether the matcher adds them or the request has them.
+	for lang, want := range map[string]string{"fr-CA": "fr-FR", "en-US-u-nu-thai": "en-US", "en-GB": "en-US"} {
+		assert.Equal(t, want, (*Negotiator)(nil).negotiate(st, newNegotiationRequest("lang="+lang, "", "")).Tag.String(), lang)
+	}
+
+	res := httptest.NewRecorder()
+	StringsHandler{ST: st}.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/strings?lang=fr-ca&fmt=po", nil))
+	assert.Equal(t, `attachment; filename="fr-fr.po"`, res.Header().Get("Content-Disposition"))
+}
+
+func newTestNegotiator(t *testing.T, precedence []string, aliases map[string]string, defaultLang string) *Negotiator {
+	neg, err := NewNegotiator(precedence, aliases, defaultLang)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return neg
+}
+
+func TestLanguagePrecedence(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour"),
+		"de-de/messages.json": gotextFile("de-de", "hello", "Hallo"),
+	})
+	req := newNegotiationRequest("lang=de-de", "fr-fr", "en-us")
+
+	assert.Equal(t, "de-DE", (*Negotiator)(nil).negotiate(st, req).Tag.String())
+	assert.Equal(t, "de-DE", newTestNegotiator(t, DefaultLanguagePrecedence, nil, "").negotiate(st, req).Tag.String())
+
+	neg := newTestNegotiator(t, []string{LanguageFromHeader, LanguageFromQuery}, nil, "")
+	assert.Equal(t, "en-US", neg.negotiate(st, req).Tag.String())
+
+	// The individual string and the catalog are negotiated the same way.
+	res := httptest.NewRecorder()
+	StringsHandler{ST: st, Negotiator: neg}.ServeHTTP(res, req)
+	assert.Equal(t, "hello,Hello\n", res.Body.String())
+
+	neg = newTestNegotiator(t, []string{LanguageFromCookie}, nil, "")
+	assert.Equal(t, "fr-FR", neg.negotiate(st, req).Tag.String())
+	assert.Equal(t, []string{LanguageFromCookie}, neg.Precedence())
+
+	for _, precedence := range [][]string{{"session"}, {LanguageFromQuery, LanguageFromQuery}, nil} {
+		_, err := NewNegotiator(precedence, nil, "")
+		assert.NotNil(t, err, precedence)
+	}
+}
+
+func TestLanguageAliases(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello"),
+		"he-il/messages.json": gotextFile("he-il", "hello", "שלום"),
+		"zh-tw/messages.json": gotextFile("zh-tw", "hello", "你好"),
+		"zh-cn/messages.json": gotextFile("zh-cn", "hello", "你好"),
+		"nb-no/messages.json": gotextFile("nb-no", "hello", "Hei"),
+	})
+	var neg *Negotiator
+
+	n := neg.negotiate(st, newNegotiationRequest("lang=iw_IL", "", ""))
+	assert.Equal(t, "he-IL", n.Tag.String())
+
+	n = neg.negotiate(st, newNegotiationRequest("lang=zh_CN", "", ""))
+	assert.Equal(t, "zh-CN", n.Tag.String())
+	assert.Empty(t, n.Path[0].Resolved)
+
+	// Individual languages aren't replaced with their macrolanguage, which would make nb-NO no-NO.
+	n = neg.negotiate(st, newNegotiationRequest("", "", "nb-NO"))
+	assert.Equal(t, "nb-NO", n.Tag.String())
+	assert.Empty(t, n.Path[0].Resolved)
+
+	neg = newTestNegotiator(t, DefaultLanguagePrecedence, map[string]string{"zh": "zh_TW", "he": "en-us"}, "")
+	n = neg.negotiate(st, newNegotiationRequest("lang=zh", "", ""))
+	assert.Equal(t, "zh-TW", n.Tag.String())
+	assert.Equal(t, "zh-TW", n.Path[0].Resolved)
+	// Aliases apply to any way of writing their tag.
+	assert.Equal(t, "en-US", neg.negotiate(st, newNegotiationRequest("", "", "iw")).Tag.String())
+
+	_, err := NewNegotiator(DefaultLanguagePrecedence, map[string]string{"zh": "not a tag"}, "")
+	assert.NotNil(t, err)
+	_, err = NewNegotiator(DefaultLanguagePrecedence, map[string]string{"iw": "he-IL", "he": "en-US"}, "")
+	assert.NotNil(t, err)
+}
+
+func TestDefaultLanguage(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour"),
+	})
+	req := newNegotiationRequest("", "", "ja")
+
+	assert.Equal(t, "en-US", (*Negotiator)(nil).negotiate(st, req).Tag.String())
+
+	neg := newTestNegotiator(t, DefaultLanguagePrecedence, nil, "fr")
+	assert.Nil(t, neg.CheckDefault(st))
+	n := neg.negotiate(st, req)
+	assert.Equal(t, "fr-FR", n.Tag.String())
+	assert.Equal(t, "No", n.Confidence)
+	assert.Equal(t, "default", n.Path[len(n.Path)-1].Source)
+	assert.Equal(t, "fr-FR", n.Path[len(n.Path)-1].Matched)
+
+	// A match is still preferred to the default.
+	assert.Equal(t, "en-US", neg.negotiate(st, newNegotiationRequest("", "", "en")).Tag.String())
+
+	_, err := NewNegotiator(DefaultLanguagePrecedence, nil, "not a tag")
+	assert.NotNil(t, err)
+	// A default that isn't loaded is caught at startup rather than serving some other language.
+	assert.NotNil(t, newTestNegotiator(t, DefaultLanguagePrecedence, nil, "de").CheckDefault(st))
+	assert.NotNil(t, newTestNegotiator(t, DefaultLanguagePrecedence, nil, "fr-CA").CheckDefault(st))
+	assert.Nil(t, (*Negotiator)(nil).CheckDefault(st))
+}
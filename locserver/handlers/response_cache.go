@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/language"
+)
+
+// maxCachedResponses bounds a ResponseCache, since key filters come from clients.
+const maxCachedResponses = 1024
+
+// ResponseCache holds serialized catalog responses for the strings a StringTable has loaded.
+// It empties itself when the StringTable loads anything new, and drops the least recently used
+// response when it is full.
+type ResponseCache struct {
+	st *loader.StringTable
+	// gzip also keeps a gzip-compressed copy of each response.
+	gzip bool
+
+	mu         sync.Mutex
+	generation uint64
+	responses  map[responseKey]*list.Element
+	// lru holds the cacheEntry of each response, most recently used first.
+	lru *list.List
+}
+
+type cacheEntry struct {
+	key  responseKey
+	resp *cachedResponse
+}
+
+// responseKey identifies a response by everything in the request that changes its body.
+type responseKey struct {
+	tag         language.Tag
+	contentType string
+	keyFilter   string
+	shape       string
+	sep         string
+}
+
+// cachedResponse is a serialized response with the headers that describe its body.
+type cachedResponse struct {
+	header  http.Header
+	body    []byte
+	etag    string
+	modTime time.Time
+	// gzipped is the body compressed with gzip, if the cache keeps compressed copies.
+	gzipped []byte
+}
+
+// NewResponseCache is the factory method for ResponseCache. With gzip, each response is compressed once up front.
+func NewResponseCache(st *loader.StringTable, gzip bool) *ResponseCache {
+	return &ResponseCache{
+		st:        st,
+		gzip:      gzip,
+		responses: map[responseKey]*list.Element{},
+		lru:       list.New(),
+	}
+}
+
+// get gets a cached response, or renders and caches it.
+func (c *ResponseCache) get(key responseKey, render func() (*cachedResponse, error)) (*cachedResponse, error) {
+	// Read the generation before rendering, so a reload during the render can only make the cache miss.
+	generation := c.st.Generation()
+
+	c.mu.Lock()
+	if generation > c.generation {
+		c.generation = generation
+		c.responses = map[responseKey]*list.Element{}
+		c.lru.Init()
+	}
+	if e, ok := c.responses[key]; ok {
+		c.lru.MoveToFront(e)
+		resp := e.Value.(*cacheEntry).resp
+		c.mu.Unlock()
+		return resp, nil
+	}
+	c.mu.Unlock()
+
+	resp, err := render()
+	if err != nil {
+		return nil, err
+	}
+	if c.gzip {
+		if resp.gzipped, err = gzipBytes(resp.body); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.responses[key]; generation != c.generation || ok {
+		return resp, nil
+	}
+	if c.lru.Len() >= maxCachedResponses {
+		oldest := c.lru.Back()
+		delete(c.responses, oldest.Value.(*cacheEntry).key)
+		c.lru.Remove(oldest)
+	}
+	c.responses[key] = c.lru.PushFront(&cacheEntry{key: key, resp: resp})
+	return resp, nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
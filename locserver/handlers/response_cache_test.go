@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+)
+
+func TestResponseCache(t *testing.T) {
+	files := fstest.MapFS{"en-us/messages.json": gotextFile("en-us", "hello", "Hello")}
+	st := newTestStringTable(t, files)
+	cache := NewResponseCache(st, true)
+	h := StringsHandler{ST: st, Cache: cache}
+
+	get := func(header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/strings?lang=en-us&fmt=application/json", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	res := get()
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `[{"id": "hello", "translation": "Hello"}]`, res.Body.String())
+	assert.Equal(t, []string{"Accept, Accept-Language, Cookie", "Accept-Encoding"}, res.Header().Values("Vary"))
+	assert.Len(t, cache.responses, 1)
+	etag := res.Header().Get("ETag")
+
+	res = get("Accept-Encoding", "br, gzip")
+	assert.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+	assert.NotEqual(t, etag, res.Header().Get("ETag"))
+	r, err := gzip.NewReader(res.Body)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"id": "hello", "translation": "Hello"}]`, string(body))
+	assert.Len(t, cache.responses, 1)
+
+	res = get("Accept-Encoding", "*, gzip;q=0")
+	assert.Empty(t, res.Header().Get("Content-Encoding"))
+
+	// A reload empties the cache.
+	files["en-us/messages.json"] = gotextFile("en-us", "hello", "Hi")
+	assert.Nil(t, st.Load())
+	res = get()
+	assert.JSONEq(t, `[{"id": "hello", "translation": "Hi"}]`, res.Body.String())
+	assert.NotEqual(t, etag, res.Header().Get("ETag"))
+	assert.Len(t, cache.responses, 1)
+}
+
+func TestResponseCacheErrorsAreNotCached(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{"en-us/messages.json": gotextFile("en-us", "a", "A", "a.b", "B")})
+	cache := NewResponseCache(st, false)
+	h := StringsHandler{ST: st, Cache: cache}
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/strings?lang=en-us&shape=nested", nil))
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Empty(t, cache.responses)
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{"en-us/messages.json": gotextFile("en-us", "hello", "Hello")})
+	cache := NewResponseCache(st, false)
+	renders := 0
+	get := func(keyFilter string) {
+		cache.get(responseKey{keyFilter: keyFilter}, func() (*cachedResponse, error) {
+			renders++
+			return &cachedResponse{}, nil
+		})
+	}
+
+	for i := 0; i < maxCachedResponses; i++ {
+		get(fmt.Sprint(i))
+	}
+	get("0")
+	get("new")
+	assert.Len(t, cache.responses, maxCachedResponses)
+	assert.Equal(t, maxCachedResponses+1, renders)
+
+	// 1 was the least recently used, so it is the one that was dropped.
+	get("0")
+	assert.Equal(t, maxCachedResponses+1, renders)
+	get("1")
+	assert.Equal(t, maxCachedResponses+2, renders)
+}
+
+func TestResponseCacheUnknownFormats(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{"en-us/messages.json": gotextFile("en-us", "hello", "Hello")})
+	cache := NewResponseCache(st, false)
+	h := StringsHandler{ST: st, Cache: cache}
+
+	for _, f := range []string{"nope", "also-nope", "text/csv"} {
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/strings?lang=en-us&fmt="+f, nil))
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "text/csv", res.Header().Get("Content-Type"))
+	}
+	assert.Len(t, cache.responses, 1)
+}
+
+func benchmarkStringsHandler(b *testing.B, cache bool, target string) {
+	idsAndTranslations := []string{}
+	for i := 0; i < 10000; i++ {
+		idsAndTranslations = append(idsAndTranslations, fmt.Sprintf("section%d.key%d", i%100, i), fmt.Sprintf("Translation number %d", i))
+	}
+	st := newTestStringTable(b, fstest.MapFS{"en-us/messages.json": gotextFile("en-us", idsAndTranslations...)})
+	h := StringsHandler{ST: st}
+	if cache {
+		h.Cache = NewResponseCache(st, false)
+	}
+
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res := &discardResponseWriter{header: http.Header{}}
+		h.ServeHTTP(res, req)
+		if res.status != http.StatusOK {
+			b.Fatal(res.status)
+		}
+	}
+}
+
+// discardResponseWriter keeps the overhead of recording responses out of benchmarks.
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardResponseWriter) Header() http.Header { return w.header }
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(status int) { w.status = status }
+
+func BenchmarkStringsHandlerCSV(b *testing.B) {
+	benchmarkStringsHandler(b, false, "/v1/strings?lang=en-us")
+}
+
+func BenchmarkStringsHandlerCSVCached(b *testing.B) {
+	benchmarkStringsHandler(b, true, "/v1/strings?lang=en-us")
+}
+
+func BenchmarkStringsHandlerJSON(b *testing.B) {
+	benchmarkStringsHandler(b, false, "/v1/strings?lang=en-us&fmt=application/json")
+}
+
+func BenchmarkStringsHandlerJSONCached(b *testing.B) {
+	benchmarkStringsHandler(b, true, "/v1/strings?lang=en-us&fmt=application/json")
+}
+
+func TestResponseCacheEdits(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "en-us"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "en-us", "messages.json"), gotextFile("en-us", "hello", "Hello").Data, 0644)
+	st, err := loader.NewStringTable(dir, true, loader.NewGoTextJSONLoader())
+	assert.Nil(t, err)
+	assert.Nil(t, st.Load())
+	defer st.Close()
+	h := StringsHandler{ST: st, Cache: NewResponseCache(st, false)}
+
+	get := func() string {
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/strings?lang=en-us&fmt=application/json", nil))
+		return res.Body.String()
+	}
+	assert.JSONEq(t, `[{"id": "hello", "translation": "Hello"}]`, get())
+
+	// An edit through the API is served at once.
+	res := httptest.NewRecorder()
+	newEditRouter(st).ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/v1/strings/hello?lang=en-us", strings.NewReader("Hi")))
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.JSONEq(t, `[{"id": "hello", "translation": "Hi"}]`, get())
+
+	// So is an edit of the file, once the watcher reloads it.
+	ioutil.WriteFile(filepath.Join(dir, "en-us", "messages.json"), gotextFile("en-us", "hello", "Hey").Data, 0644)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(get(), `"Hey"`)
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+)
+
+// RevisionHeader is the response header that identifies the revision of the locales being served.
+const RevisionHeader = "X-Locales-Revision"
+
+// StatusHandler handles a request for the state of the loaded locales.
+type StatusHandler struct {
+	ST *loader.StringTable
+}
+
+type status struct {
+	Revision  string    `json:"revision,omitempty"`
+	Languages []string  `json:"languages"`
+	LoadedAt  time.Time `json:"loaded_at"`
+}
+
+func (h StatusHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	data := status{
+		Revision:  h.ST.Revision(),
+		Languages: []string{},
+		LoadedAt:  h.ST.LoadedAt(),
+	}
+	for _, t := range h.ST.Tags() {
+		data.Languages = append(data.Languages, t.String())
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(res).Encode(data)
+	if err != nil {
+		log.Error().Err(err).Msg("Unexpected error writing status")
+	}
+}
+
+// RevisionMiddleware adds the RevisionHeader to every response, if the locales have a revision.
+func RevisionMiddleware(st *loader.StringTable) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if rev := st.Revision(); rev != "" {
+				res.Header().Set(RevisionHeader, rev)
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
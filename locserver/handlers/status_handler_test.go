@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+)
+
+func newTestStringTable(t testing.TB, files fstest.MapFS) *loader.StringTable {
+	st := loader.NewStringTableFS(files, loader.NewGoTextJSONLoader())
+	if err := st.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func gotextFile(lang string, idsAndTranslations ...string) *fstest.MapFile {
+	msgs := []map[string]string{}
+	for i := 0; i+1 < len(idsAndTranslations); i += 2 {
+		msgs = append(msgs, map[string]string{
+			"id":          idsAndTranslations[i],
+			"message":     idsAndTranslations[i],
+			"translation": idsAndTranslations[i+1],
+		})
+	}
+	data, _ := json.Marshal(map[string]interface{}{"language": lang, "messages": msgs})
+	return &fstest.MapFile{Data: data}
+}
+
+func TestStatusHandler(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour"),
+	})
+
+	router := mux.NewRouter()
+	router.Handle("/v1/status", StatusHandler{ST: st})
+	router.Use(RevisionMiddleware(st))
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/status", nil))
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	// Not a Versioned source.
+	assert.Equal(t, "", res.Header().Get(RevisionHeader))
+
+	var body status
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"en-US", "fr-FR"}, body.Languages)
+	assert.Equal(t, "", body.Revision)
+	assert.False(t, body.LoadedAt.IsZero())
+}
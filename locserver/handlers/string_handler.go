@@ -1,33 +1,118 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 	"github.com/scottmcmaster/go-loc-server/locserver/loader"
-	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 )
 
+// MissingStringHeader is set on the response for a key the negotiated language has no translation of.
+const MissingStringHeader = "X-Missing-String"
+
+// MissingKeyPolicy is what StringHandler returns for a key the negotiated language has no translation of.
+type MissingKeyPolicy string
+
+const (
+	// MissingKeyReturnKey returns the key itself. It is the default.
+	MissingKeyReturnKey MissingKeyPolicy = "key"
+	// MissingKeyReturnSource returns the translation in the source language, or the key if there is none.
+	MissingKeyReturnSource MissingKeyPolicy = "source"
+	// MissingKeyNotFound returns 404.
+	MissingKeyNotFound MissingKeyPolicy = "404"
+	// MissingKeyPseudo returns the key pseudo-localized, so it stands out in a UI.
+	MissingKeyPseudo MissingKeyPolicy = "pseudo"
+	// MissingKeyEmpty returns an empty string.
+	MissingKeyEmpty MissingKeyPolicy = "empty"
+)
+
+var missingKeyPolicies = []MissingKeyPolicy{
+	MissingKeyReturnKey, MissingKeyReturnSource, MissingKeyNotFound, MissingKeyPseudo, MissingKeyEmpty,
+}
+
+// ParseMissingKeyPolicy checks the name of a missing key policy.
+func ParseMissingKeyPolicy(name string) (MissingKeyPolicy, error) {
+	for _, p := range missingKeyPolicies {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown missing key policy %q, expected one of %v", name, missingKeyPolicies)
+}
+
 // StringHandler handles a request for an individual string.
+// The missing query param overrides the MissingKey policy for a request.
 type StringHandler struct {
 	ST *loader.StringTable
+	// Negotiator matches request languages to the loaded ones, with the defaults if nil.
+	Negotiator *Negotiator
+	// MissingKey is what to return for a key without a translation, MissingKeyReturnKey if unset.
+	MissingKey MissingKeyPolicy
+	// SourceLang is the language of the MissingKeyReturnSource policy.
+	SourceLang string
+	// Missing records lookups of keys without a translation, if set.
+	Missing *MissingKeys
+	// Usage counts lookups of keys with a translation, if set.
+	Usage *KeyUsage
 }
 
 func (h StringHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	lang, accept, param := ExtractLang(req)
 	vars := mux.Vars(req)
+	key := vars["str"]
+
+	policy := h.MissingKey
+	if override := req.URL.Query().Get("missing"); override != "" {
+		var err error
+		if policy, err = ParseMissingKeyPolicy(override); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("400 - " + err.Error()))
+			return
+		}
+	}
 
-	tag, _ := language.MatchStrings(*h.ST.Matcher, lang, accept, param)
+	tag := h.Negotiator.negotiate(h.ST, req).Tag
 	p := message.NewPrinter(tag)
 
-	str := p.Sprintf(vars["str"])
+	missing := true
+	if cat, err := h.ST.StringsByTag(tag); err == nil {
+		_, ok := cat.Strings[key]
+		missing = !ok
+	}
+
+	str := p.Sprintf(key)
+	if !missing {
+		h.Usage.Hit(key, tag)
+	} else {
+		h.Missing.Record(key, tag, req.Referer(), false)
+		res.Header().Set(MissingStringHeader, "true")
+		switch policy {
+		case MissingKeyReturnSource:
+			if source, ok := sourceTag(h.ST, h.SourceLang); ok {
+				str = message.NewPrinter(source).Sprintf(key)
+			}
+		case MissingKeyNotFound:
+			log.Debug().Str("key", key).Str("language_tag", tag.String()).Msg("String not found")
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte("404 - String not found"))
+			return
+		case MissingKeyPseudo:
+			str = pseudoLocalize(key)
+		case MissingKeyEmpty:
+			str = ""
+		}
+	}
 	log.Debug().
 		Str("str", str).
+		Str("param", param).
 		Str("cookie", lang).
 		Str("accept", accept).
 		Str("language_tag", tag.String()).
+		Bool("missing", missing).
 		Msg("Returning string")
 
 	data := []byte(str)
@@ -35,3 +120,23 @@ func (h StringHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	res.WriteHeader(http.StatusOK)
 	res.Write(data)
 }
+
+var pseudoLetters = strings.NewReplacer(
+	"a", "á", "c", "ç", "e", "é", "i", "í", "n", "ñ", "o", "ó", "u", "ú", "y", "ý",
+	"A", "Á", "C", "Ç", "E", "É", "I", "Í", "N", "Ñ", "O", "Ó", "U", "Ú", "Y", "Ý",
+)
+
+// pseudoLocalize accents the letters of a string and brackets it, leaving its printf verbs alone.
+func pseudoLocalize(s string) string {
+	var buf strings.Builder
+	buf.WriteString("[!! ")
+	last := 0
+	for _, m := range printfVerb.FindAllStringIndex(s, -1) {
+		buf.WriteString(pseudoLetters.Replace(s[last:m[0]]))
+		buf.WriteString(s[m[0]:m[1]])
+		last = m[1]
+	}
+	buf.WriteString(pseudoLetters.Replace(s[last:]))
+	buf.WriteString(" !!]")
+	return buf.String()
+}
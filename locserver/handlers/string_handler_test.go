@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringHandlerMissingKey(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello", "goodbye", "Goodbye"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour"),
+	})
+	get := func(h StringHandler, key, target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, target, nil), map[string]string{"str": key})
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	h := StringHandler{ST: st, SourceLang: "en-us"}
+	res := get(h, "hello", "/v1/strings/hello?lang=fr-fr")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "Bonjour", res.Body.String())
+	assert.Empty(t, res.Header().Get(MissingStringHeader))
+
+	res = get(h, "goodbye", "/v1/strings/goodbye?lang=fr-fr")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "goodbye", res.Body.String())
+	assert.Equal(t, "true", res.Header().Get(MissingStringHeader))
+
+	for policy, want := range map[MissingKeyPolicy]string{
+		MissingKeyReturnKey:    "goodbye",
+		MissingKeyReturnSource: "Goodbye",
+		MissingKeyPseudo:       "[!! góódbýé !!]",
+		MissingKeyEmpty:        "",
+	} {
+		res = get(StringHandler{ST: st, SourceLang: "en-us", MissingKey: policy}, "goodbye", "/v1/strings/goodbye?lang=fr-fr")
+		assert.Equal(t, http.StatusOK, res.Code, policy)
+		assert.Equal(t, want, res.Body.String(), policy)
+		assert.Equal(t, "true", res.Header().Get(MissingStringHeader), policy)
+	}
+
+	h.MissingKey = MissingKeyNotFound
+	res = get(h, "goodbye", "/v1/strings/goodbye?lang=fr-fr")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Equal(t, "true", res.Header().Get(MissingStringHeader))
+
+	// The policy can be overridden per request.
+	res = get(h, "goodbye", "/v1/strings/goodbye?lang=fr-fr&missing=source")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "Goodbye", res.Body.String())
+
+	res = get(h, "goodbye", "/v1/strings/goodbye?lang=fr-fr&missing=shrug")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	// A key missing from the source too comes back as itself.
+	res = get(StringHandler{ST: st, SourceLang: "en-us", MissingKey: MissingKeyReturnSource}, "unknown", "/v1/strings/unknown?lang=fr-fr")
+	assert.Equal(t, "unknown", res.Body.String())
+}
+
+func TestPseudoLocalize(t *testing.T) {
+	assert.Equal(t, "[!! Ýóú hávé %d ñéw mésságés !!]", pseudoLocalize("You have %d new messages"))
+	assert.Equal(t, "[!!  !!]", pseudoLocalize(""))
+}
+
+func TestParseMissingKeyPolicy(t *testing.T) {
+	p, err := ParseMissingKeyPolicy("pseudo")
+	assert.Nil(t, err)
+	assert.Equal(t, MissingKeyPseudo, p)
+
+	_, err = ParseMissingKeyPolicy("")
+	assert.NotNil(t, err)
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -12,10 +16,21 @@ import (
 )
 
 // StringsHandler handles a request for a full string catalog by language.
+// Responses have a strong ETag of their content, and the Last-Modified time of the catalog, for conditional requests.
 type StringsHandler struct {
 	ST *loader.StringTable
+	// Negotiator matches request languages to the loaded ones, with the defaults if nil.
+	Negotiator *Negotiator
+	// CacheControl is the Cache-Control header of catalog responses, if any.
+	CacheControl string
+	// Cache keeps serialized responses until the strings are reloaded, if not nil.
+	Cache *ResponseCache
+	// SourceLang is the language of the keys, for the file formats that name it.
+	SourceLang string
 }
 
+var errLanguageNotFound = errors.New("language not found")
+
 type stringTranslation struct {
 	ID          string `json:"id"`
 	Translation string `json:"translation"`
@@ -25,67 +40,211 @@ func (h StringsHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	lang, acceptLang, param := ExtractLang(req)
 	contentType := ExtractContentType(req)
 	keyFilter := GetQueryParam(req, "kf")
+	shape := GetQueryParam(req, "shape")
+	if shape == "" {
+		shape = ShapeList
+		if contentType == ESMContentType {
+			shape = ShapeFlat
+		}
+	}
+	sep := GetQueryParam(req, "sep")
+	if sep == "" {
+		sep = DefaultKeySeparator
+	}
+	if !supportedShapes[shape] {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("400 - Unknown shape " + shape))
+		return
+	}
+	if shape != ShapeList && contentType != ESMContentType {
+		// Only JSON has shapes other than a list.
+		contentType = "application/json"
+	}
 
-	tag, _ := language.MatchStrings(*h.ST.Matcher, param, lang, acceptLang)
+	tag := h.Negotiator.negotiate(h.ST, req).Tag
 
 	log.Debug().
+		Str("param", param).
 		Str("cookie", lang).
 		Str("accept", acceptLang).
 		Str("content_type", contentType).
 		Str("key_filter", keyFilter).
+		Str("shape", shape).
 		Str("language_tag", tag.String()).
 		Msg("Returning strings")
 
-	strs, err := h.ST.Loader.StringsByTag(tag)
-	if err != nil {
+	key := responseKey{tag: tag, contentType: contentType, keyFilter: keyFilter, shape: shape, sep: sep}
+	render := func() (*cachedResponse, error) {
+		return h.render(key)
+	}
+	var resp *cachedResponse
+	var err error
+	if h.Cache != nil {
+		resp, err = h.Cache.get(key, render)
+	} else {
+		resp, err = render()
+	}
+
+	var conflict *KeyConflictError
+	switch {
+	case errors.Is(err, errLanguageNotFound):
 		log.Error().Str("language_tag", tag.String()).Err(err).
 			Msg("Getting strings for tag")
 		res.WriteHeader(http.StatusNotFound)
 		res.Write([]byte("404 - Language not found"))
 		return
+	case errors.As(err, &conflict):
+		log.Debug().Str("language_tag", tag.String()).Err(err).Msg("Unable to nest strings")
+		res.WriteHeader(http.StatusConflict)
+		res.Write([]byte("409 - " + err.Error()))
+		return
+	case err != nil:
+		log.Error().Str("language_tag", tag.String()).Err(err).
+			Msg("Unexpected error writing response")
+		res.WriteHeader(http.StatusInternalServerError)
+		res.Write([]byte("500 - Unexpected error"))
+		return
+	}
+
+	for k, v := range resp.header {
+		res.Header()[k] = v
+	}
+	res.Header().Add("Vary", "Accept, Accept-Language, Cookie")
+	if resp.gzipped != nil {
+		addVary(res.Header(), "Accept-Encoding")
+		if acceptsEncoding(req, "gzip") {
+			res.Header().Set("Content-Encoding", "gzip")
+			serveContent(res, req, weakETag(resp.etag), resp.modTime, h.CacheControl, resp.gzipped)
+			return
+		}
 	}
+	serveContent(res, req, resp.etag, resp.modTime, h.CacheControl, resp.body)
+}
 
-	switch contentType {
+// render serializes the strings of a language as a response.
+func (h StringsHandler) render(key responseKey) (*cachedResponse, error) {
+	strs, err := h.ST.StringsByTag(key.tag)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errLanguageNotFound, err)
+	}
+
+	var body bytes.Buffer
+	header := http.Header{}
+	switch key.contentType {
 	case "application/json":
-		err = writeJSON(res, strs, keyFilter)
+		err = writeJSON(&body, header, strs, key.keyFilter, key.shape, key.sep)
+	case ESMContentType:
+		err = writeESM(&body, header, strs, key.keyFilter, key.shape, key.sep)
 	case "text/csv":
-		fallthrough
+		err = writeCSV(&body, header, strs, key.keyFilter)
 	default:
-		err = writeCSV(res, strs, keyFilter)
+		if f, ok := writerForContentType(key.contentType); ok {
+			err = writeFormat(&body, header, f, key.tag, language.Make(h.SourceLang), strs, key.keyFilter)
+		} else {
+			err = writeCSV(&body, header, strs, key.keyFilter)
+		}
 	}
-
 	if err != nil {
-		log.Error().Str("language_tag", tag.String()).Err(err).
-			Msg("Unexpected error writing response")
-		res.WriteHeader(http.StatusInternalServerError)
-		res.Write([]byte("500 - Unexpected error"))
-		return
+		return nil, err
 	}
+
+	return &cachedResponse{
+		header:  header,
+		body:    body.Bytes(),
+		etag:    ETag(header.Get("Content-Type"), body.Bytes()),
+		modTime: strs.LastModTime,
+	}, nil
 }
 
-func writeCSV(res http.ResponseWriter, strs *loader.StringCatalog, keyFilter string) error {
-	res.Header().Set("Content-Type", "text/csv")
-	w := csv.NewWriter(res)
-	defer w.Flush()
-	for k, v := range strs.Strings {
+func writeCSV(out io.Writer, header http.Header, strs *loader.StringCatalog, keyFilter string) error {
+	header.Set("Content-Type", "text/csv")
+	w := csv.NewWriter(out)
+	for _, k := range sortedKeys(strs.Strings) {
 		if strings.HasPrefix(k, keyFilter) {
-			w.Write([]string{k, v})
+			w.Write([]string{k, strs.Strings[k]})
 		}
 	}
-	return nil
+	w.Flush()
+	return w.Error()
+}
+
+func writeJSON(out io.Writer, header http.Header, strs *loader.StringCatalog, keyFilter, shape, sep string) error {
+	data, err := shapeStrings(strs, keyFilter, shape, sep)
+	if err != nil {
+		return err
+	}
+	header.Set("Content-Type", "application/json")
+	return json.NewEncoder(out).Encode(data)
 }
 
-func writeJSON(res http.ResponseWriter, strs *loader.StringCatalog, keyFilter string) error {
-	res.Header().Set("Content-Type", "application/json")
-	data := []stringTranslation{}
+// writeESM writes the strings as a JavaScript module with the JSON as its default export, for dynamic import().
+func writeESM(out io.Writer, header http.Header, strs *loader.StringCatalog, keyFilter, shape, sep string) error {
+	data, err := shapeStrings(strs, keyFilter, shape, sep)
+	if err != nil {
+		return err
+	}
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	header.Set("Content-Type", ESMContentType+"; charset=utf-8")
+	_, err = out.Write([]byte("export default " + string(js) + ";\n"))
+	return err
+}
+
+// shapeStrings filters the strings by key prefix and arranges them in a JSON shape.
+func shapeStrings(strs *loader.StringCatalog, keyFilter, shape, sep string) (interface{}, error) {
+	filtered := map[string]string{}
 	for k, v := range strs.Strings {
 		if strings.HasPrefix(k, keyFilter) {
-			data = append(data, stringTranslation{
-				Translation: v,
+			filtered[k] = v
+		}
+	}
+
+	switch shape {
+	case ShapeFlat:
+		return filtered, nil
+	case ShapeNested:
+		return nestStrings(filtered, sep)
+	default:
+		list := []stringTranslation{}
+		for _, k := range sortedKeys(filtered) {
+			list = append(list, stringTranslation{
+				Translation: filtered[k],
 				ID:          k,
 			})
 		}
+		return list, nil
 	}
+}
 
-	return json.NewEncoder(res).Encode(data)
+// writeFormat writes the strings as a file in the native format of a client platform, ready to download.
+func writeFormat(out io.Writer, header http.Header, f loader.WriterFormat, tag, source language.Tag, strs *loader.StringCatalog, keyFilter string) error {
+	filtered := &loader.StringCatalog{
+		Strings:     map[string]string{},
+		Metadata:    map[string]map[string]string{},
+		LastModTime: strs.LastModTime,
+	}
+	for k, v := range strs.Strings {
+		if strings.HasPrefix(k, keyFilter) {
+			filtered.Strings[k] = v
+			if meta, ok := strs.Metadata[k]; ok {
+				filtered.Metadata[k] = meta
+			}
+		}
+	}
+
+	warnings, err := f.Write(out, tag, source, filtered)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		log.Debug().Str("language_tag", tag.String()).Str("format", f.Name).Msg(w)
+	}
+
+	header.Set("Content-Type", f.ContentType+"; charset=utf-8")
+	if len(f.Extensions) > 0 {
+		header.Set("Content-Disposition", `attachment; filename="`+strings.ToLower(tag.String())+f.Extensions[0]+`"`)
+	}
+	return nil
 }
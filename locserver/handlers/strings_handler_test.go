@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringsHandlerFormats(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"fr-fr/messages.json": gotextFile("fr-fr", "home.title", "Accueil", "home.intro", "C'est ici", "about", "À propos"),
+	})
+	h := StringsHandler{ST: st}
+
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	res := get("/v1/strings?lang=fr-fr&fmt=android&kf=home.", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/x-android-strings+xml; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="fr-fr.xml"`, res.Header().Get("Content-Disposition"))
+	assert.Contains(t, res.Body.String(), `<string name="home.intro">C\'est ici</string>`)
+	assert.NotContains(t, res.Body.String(), "about")
+
+	res = get("/v1/strings?lang=fr-fr", "text/html, text/x-java-properties")
+	assert.Equal(t, "text/x-java-properties; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Contains(t, res.Body.String(), `about=\u00C0 propos`)
+
+	res = get("/v1/strings?lang=fr-fr&fmt=po", "")
+	assert.Equal(t, `attachment; filename="fr-fr.po"`, res.Header().Get("Content-Disposition"))
+	assert.Contains(t, res.Body.String(), `msgstr "Accueil"`)
+
+	// The original formats are unchanged.
+	res = get("/v1/strings?lang=fr-fr&fmt=application/json", "")
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	assert.Empty(t, res.Header().Get("Content-Disposition"))
+	res = get("/v1/strings?lang=fr-fr&fmt=csv", "")
+	assert.Equal(t, "text/csv", res.Header().Get("Content-Type"))
+	assert.Contains(t, res.Body.String(), "about,À propos")
+}
+
+func TestStringsHandlerJSONShapes(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"de-de/messages.json": gotextFile("de-de", "users.errors.not_found", "Nicht gefunden", "users.title", "Benutzer"),
+		"nl-nl/messages.json": gotextFile("nl-nl", "users", "Gebruikers", "users.title", "Gebruikers"),
+	})
+	h := StringsHandler{ST: st}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+		return res
+	}
+
+	res := get("/v1/strings?lang=de-de&shape=nested")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"users": {"errors": {"not_found": "Nicht gefunden"}, "title": "Benutzer"}}`, res.Body.String())
+
+	res = get("/v1/strings?lang=de-de&shape=nested&sep=_")
+	assert.JSONEq(t, `{"users.errors.not": {"found": "Nicht gefunden"}, "users.title": "Benutzer"}`, res.Body.String())
+
+	res = get("/v1/strings?lang=de-de&shape=flat&kf=users.t")
+	assert.JSONEq(t, `{"users.title": "Benutzer"}`, res.Body.String())
+
+	res = get("/v1/strings?lang=nl-nl&shape=nested")
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Contains(t, res.Body.String(), "users")
+
+	res = get("/v1/strings?lang=nl-nl&shape=flat")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = get("/v1/strings?lang=nl-nl&shape=tree")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestStringsHandlerESM(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"de-de/messages.json": gotextFile("de-de", "users.title", "Benutzer", "home", "Start"),
+	})
+	h := StringsHandler{ST: st}
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/strings?lang=de-de&fmt=esm", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "text/javascript; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, `export default {"home":"Start","users.title":"Benutzer"};`+"\n", res.Body.String())
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/strings?lang=de-de&fmt=esm&shape=nested", nil))
+	assert.Equal(t, `export default {"home":"Start","users":{"title":"Benutzer"}};`+"\n", res.Body.String())
+}
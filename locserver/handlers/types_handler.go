@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+)
+
+// TypesHandler handles a request for a TypeScript declaration file of the string keys, from the source language.
+// MessageArgs maps each key to a tuple of the arguments its placeholders take, and the default export
+// describes the module served by fmt=esm.
+type TypesHandler struct {
+	ST         *loader.StringTable
+	SourceLang string
+	// CacheControl is the Cache-Control header of responses, if any.
+	CacheControl string
+}
+
+func (h TypesHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	tag, ok := sourceTag(h.ST, h.SourceLang)
+	if !ok {
+		log.Error().Str("source_lang", h.SourceLang).Msg("Source language not loaded")
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("404 - Source language not found"))
+		return
+	}
+	strs, err := h.ST.StringsByTag(tag)
+	if err != nil {
+		log.Error().Str("language_tag", tag.String()).Err(err).Msg("Getting source strings")
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("404 - Source language not found"))
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Generated by go-loc-server from the " + tag.String() + " strings.\n\n")
+	buf.WriteString("export interface MessageArgs {\n")
+	for _, k := range sortedKeys(strs.Strings) {
+		key, _ := json.Marshal(k)
+		buf.WriteString("  " + string(key) + ": [" + strings.Join(placeholderArgs(strs.Strings[k], strs.Metadata[k]), ", ") + "];\n")
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString("export type MessageKey = keyof MessageArgs;\n\n")
+	buf.WriteString("declare const messages: Record<MessageKey, string>;\n")
+	buf.WriteString("export default messages;\n")
+
+	contentType := "application/typescript; charset=utf-8"
+	res.Header().Set("Content-Type", contentType)
+	serveContent(res, req, ETag(contentType, buf.Bytes()), strs.LastModTime, h.CacheControl, buf.Bytes())
+}
+
+// printfVerb matches a printf verb with an optional explicit argument index, such as %d or %[2]s.
+var printfVerb = regexp.MustCompile(`%(?:\[(\d+)\])?[-+# 0]*\d*(?:\.\d+)?([a-zA-Z%])`)
+
+// placeholderArgs gets the TypeScript types of the arguments a string takes, from its printf verbs followed by
+// an object of its named ICU placeholders. Plural forms in the metadata are included.
+func placeholderArgs(value string, meta map[string]string) []string {
+	msgs := []string{value}
+	for k, v := range meta {
+		if strings.HasPrefix(k, loader.MetadataPluralPrefix) {
+			msgs = append(msgs, v)
+		}
+	}
+
+	positional := map[int]string{}
+	count := 0
+	named := map[string]string{}
+	for _, msg := range msgs {
+		next := 1
+		for _, m := range printfVerb.FindAllStringSubmatch(msg, -1) {
+			if m[2] == "%" {
+				continue
+			}
+			if m[1] != "" {
+				next, _ = strconv.Atoi(m[1])
+			}
+			if _, ok := positional[next]; !ok || positional[next] == "unknown" {
+				positional[next] = printfType(m[2])
+			}
+			if next > count {
+				count = next
+			}
+			next++
+		}
+		icuArgs(msg, named)
+	}
+
+	args := []string{}
+	for i := 1; i <= count; i++ {
+		t, ok := positional[i]
+		if !ok {
+			t = "unknown"
+		}
+		args = append(args, t)
+	}
+	if len(named) > 0 {
+		fields := []string{}
+		for _, n := range sortedKeys(named) {
+			name, _ := json.Marshal(n)
+			fields = append(fields, string(name)+": "+named[n])
+		}
+		args = append(args, "{ "+strings.Join(fields, "; ")+" }")
+	}
+	return args
+}
+
+func printfType(verb string) string {
+	switch verb {
+	case "d", "b", "o", "x", "X", "c", "U", "e", "E", "f", "F", "g", "G":
+		return "number"
+	case "s", "q":
+		return "string"
+	case "t":
+		return "boolean"
+	default:
+		return "unknown"
+	}
+}
+
+// icuArgs adds the placeholders of an ICU message, such as {name} or {count, plural, one{...} other{...}}, to args.
+func icuArgs(msg string, args map[string]string) {
+	p := icuParser{msg: msg, args: args}
+	for p.pos < len(p.msg) {
+		p.message()
+		// Skip an unbalanced closing brace.
+		p.pos++
+	}
+}
+
+type icuParser struct {
+	msg  string
+	pos  int
+	args map[string]string
+}
+
+// message parses text and placeholders up to the closing brace of the enclosing case, or the end.
+func (p *icuParser) message() {
+	for p.pos < len(p.msg) {
+		switch p.msg[p.pos] {
+		case '\'':
+			// An apostrophe before a brace quotes literal text up to the next one; any other is literal itself.
+			if p.pos+1 < len(p.msg) && strings.ContainsRune("{}#|", rune(p.msg[p.pos+1])) {
+				if end := strings.IndexByte(p.msg[p.pos+1:], '\''); end >= 0 {
+					p.pos += end + 2
+				} else {
+					p.pos = len(p.msg)
+				}
+			} else {
+				p.pos++
+			}
+		case '{':
+			p.pos++
+			p.placeholder()
+		case '}':
+			return
+		default:
+			p.pos++
+		}
+	}
+}
+
+func (p *icuParser) placeholder() {
+	name := p.token()
+	if name == "" || !isIdentifier(name) {
+		p.skipPlaceholder()
+		return
+	}
+	p.skipSpace()
+	if p.pos < len(p.msg) && p.msg[p.pos] == '}' {
+		p.pos++
+		p.addArg(name, "string | number")
+		return
+	}
+	if p.pos >= len(p.msg) || p.msg[p.pos] != ',' {
+		p.skipPlaceholder()
+		return
+	}
+	p.pos++
+
+	kind := p.token()
+	switch kind {
+	case "plural", "selectordinal":
+		p.addArg(name, "number")
+	case "select":
+		p.addArg(name, "string")
+	case "number":
+		p.addArg(name, "number")
+	case "date", "time":
+		p.addArg(name, "Date")
+	default:
+		p.addArg(name, "string | number")
+	}
+	if kind != "plural" && kind != "selectordinal" && kind != "select" {
+		p.skipPlaceholder()
+		return
+	}
+
+	// The cases of a plural or select are messages in braces after their selectors.
+	for p.pos < len(p.msg) {
+		p.skipSpace()
+		if p.pos >= len(p.msg) {
+			return
+		}
+		switch p.msg[p.pos] {
+		case '}':
+			p.pos++
+			return
+		case '{':
+			p.pos++
+			p.message()
+			p.pos++
+		default:
+			p.pos++
+		}
+	}
+}
+
+func (p *icuParser) addArg(name, t string) {
+	if old, ok := p.args[name]; !ok || old == "string | number" {
+		p.args[name] = t
+	}
+}
+
+// token skips space and reads up to the next space, comma or brace.
+func (p *icuParser) token() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.msg) && !strings.ContainsRune(" \t\n,{}", rune(p.msg[p.pos])) {
+		p.pos++
+	}
+	return p.msg[start:p.pos]
+}
+
+func (p *icuParser) skipSpace() {
+	for p.pos < len(p.msg) && strings.ContainsRune(" \t\n", rune(p.msg[p.pos])) {
+		p.pos++
+	}
+}
+
+// skipPlaceholder moves past the brace that closes the current placeholder, including any nested in it.
+func (p *icuParser) skipPlaceholder() {
+	depth := 0
+	for ; p.pos < len(p.msg); p.pos++ {
+		switch p.msg[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				p.pos++
+				return
+			}
+			depth--
+		}
+	}
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholderArgs(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		meta  map[string]string
+		args  []string
+	}{
+		{value: "Hello", args: []string{}},
+		{value: "100%% sure", args: []string{}},
+		{value: "%s has %d files", args: []string{"string", "number"}},
+		{value: "%[2]s before %[1]d", args: []string{"number", "string"}},
+		{value: "%[3]v", args: []string{"unknown", "unknown", "unknown"}},
+		{value: "Hi {name}, it's {when, date, short}", args: []string{`{ "name": string | number; "when": Date }`}},
+		{value: "'{literal}' {n}", args: []string{`{ "n": string | number }`}},
+		{
+			value: "{count, plural, one{# file in {folder}} other{# files in {folder}}}",
+			args:  []string{`{ "count": number; "folder": string | number }`},
+		},
+		{value: "{gender, select, male{He} female{She} other{They}}", args: []string{`{ "gender": string }`}},
+		{
+			value: "%d files",
+			meta:  map[string]string{"plural.one": "%d file", "plural.other": "%d files"},
+			args:  []string{"number"},
+		},
+	} {
+		assert.Equal(t, tc.args, placeholderArgs(tc.value, tc.meta), tc.value)
+	}
+}
+
+func TestTypesHandler(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "home.title", "Home", "greeting", "Hello, %s!"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "home.title", "Accueil", "extra", "En plus"),
+	})
+
+	res := httptest.NewRecorder()
+	TypesHandler{ST: st, SourceLang: "en-us"}.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/strings.d.ts?lang=fr-fr", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/typescript; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, `// Generated by go-loc-server from the en-US strings.
+
+export interface MessageArgs {
+  "greeting": [string];
+  "home.title": [];
+}
+
+export type MessageKey = keyof MessageArgs;
+
+declare const messages: Record<MessageKey, string>;
+export default messages;
+`, res.Body.String())
+
+	// Another language's keys aren't served when the source language isn't loaded.
+	res = httptest.NewRecorder()
+	TypesHandler{ST: st, SourceLang: "de-de"}.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/strings.d.ts", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
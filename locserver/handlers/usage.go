@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/language"
+)
+
+// KeyUsage counts the lookups of each key in each language, to find keys no one requests.
+// Counting doesn't lock, and the counts are saved to a file periodically and loaded from it on start.
+type KeyUsage struct {
+	// Path is the file the counts are saved to, if any.
+	Path string
+
+	counters sync.Map // usageID to *usageCounter
+	// since is when counting began, the first time if the counts were loaded from Path.
+	since time.Time
+	now   func() time.Time
+	done  chan struct{}
+	// saveMu keeps saves from overlapping.
+	saveMu sync.Mutex
+}
+
+type usageID struct {
+	key  string
+	lang string
+}
+
+type usageCounter struct {
+	hits atomic.Int64
+	// first and last are the times of the first and last hits, in Unix nanoseconds.
+	first atomic.Int64
+	last  atomic.Int64
+}
+
+// usageFile is how KeyUsage is saved: the counts of each key by language.
+type usageFile struct {
+	Since     time.Time                        `json:"since"`
+	SavedAt   time.Time                        `json:"saved_at"`
+	Languages map[string]map[string]usageCount `json:"languages"`
+}
+
+type usageCount struct {
+	Hits      int64     `json:"hits"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// NewKeyUsage is the factory method for KeyUsage. It loads the counts saved in path, if there are any, and
+// saves them there every interval until Close. An empty path keeps the counts in memory only.
+func NewKeyUsage(path string, interval time.Duration) (*KeyUsage, error) {
+	u := &KeyUsage{
+		Path: path,
+		now:  time.Now,
+		done: make(chan struct{}),
+	}
+	u.since = u.now()
+	if path != "" {
+		if err := u.load(); err != nil {
+			return nil, err
+		}
+		if interval > 0 {
+			go u.saveEvery(interval)
+		}
+	}
+	return u, nil
+}
+
+// LoadKeyUsage reads counts saved by a KeyUsage, for reporting on them.
+func LoadKeyUsage(path string) (*KeyUsage, error) {
+	u := &KeyUsage{Path: path, now: time.Now, done: make(chan struct{})}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return u, u.decode(data)
+}
+
+func (u *KeyUsage) load() error {
+	data, err := ioutil.ReadFile(u.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return u.decode(data)
+}
+
+func (u *KeyUsage) decode(data []byte) error {
+	var file usageFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	if !file.Since.IsZero() {
+		u.since = file.Since
+	}
+	for lang, counts := range file.Languages {
+		for key, count := range counts {
+			c := &usageCounter{}
+			c.hits.Store(count.Hits)
+			if count.Hits > 0 {
+				c.first.Store(count.FirstSeen.UnixNano())
+				c.last.Store(count.LastSeen.UnixNano())
+			}
+			u.counters.Store(usageID{key: key, lang: lang}, c)
+		}
+	}
+	return nil
+}
+
+// Hit counts a lookup of a key in a language, the loaded one negotiated for the lookup, as Report looks
+// the counts up by. It is safe to call on a nil KeyUsage.
+func (u *KeyUsage) Hit(key string, tag language.Tag) {
+	if u == nil {
+		return
+	}
+	id := usageID{key: key, lang: tag.String()}
+
+	v, ok := u.counters.Load(id)
+	if !ok {
+		v, _ = u.counters.LoadOrStore(id, &usageCounter{})
+	}
+	c := v.(*usageCounter)
+	now := u.now().UnixNano()
+	c.hits.Add(1)
+	c.first.CompareAndSwap(0, now)
+	c.last.Store(now)
+}
+
+// Save writes the counts to Path, replacing the file in one step so that no one sees it partly written.
+func (u *KeyUsage) Save() error {
+	if u.Path == "" {
+		return nil
+	}
+	u.saveMu.Lock()
+	defer u.saveMu.Unlock()
+
+	file := usageFile{Since: u.since, SavedAt: u.now(), Languages: map[string]map[string]usageCount{}}
+	u.counters.Range(func(k, v interface{}) bool {
+		id, c := k.(usageID), v.(*usageCounter)
+		if file.Languages[id.lang] == nil {
+			file.Languages[id.lang] = map[string]usageCount{}
+		}
+		file.Languages[id.lang][id.key] = usageCount{
+			Hits:      c.hits.Load(),
+			FirstSeen: time.Unix(0, c.first.Load()).UTC(),
+			LastSeen:  time.Unix(0, c.last.Load()).UTC(),
+		}
+		return true
+	})
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(u.Path), ".usage-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), u.Path)
+}
+
+func (u *KeyUsage) saveEvery(interval time.Duration) {
+	for {
+		select {
+		case <-u.done:
+			return
+		case <-time.After(interval):
+		}
+		if err := u.Save(); err != nil {
+			log.Warn().Str("path", u.Path).Err(err).Msg("Error saving key usage")
+		}
+	}
+}
+
+// Close stops saving periodically and saves the counts one last time.
+func (u *KeyUsage) Close() error {
+	close(u.done)
+	return u.Save()
+}
+
+// UsageReport lists the keys of each loaded language that weren't looked up in a time window.
+type UsageReport struct {
+	// Since is when counting began.
+	Since time.Time `json:"since"`
+	// UnusedSince is the start of the window, or Since if the window began before counting did.
+	UnusedSince time.Time       `json:"unused_since"`
+	Languages   []LanguageUsage `json:"languages"`
+}
+
+// LanguageUsage is the usage of the keys of a language.
+type LanguageUsage struct {
+	Language string `json:"language"`
+	Keys     int    `json:"keys"`
+	// Requested is the number of keys looked up in the window.
+	Requested int `json:"requested"`
+	// Hits is the number of lookups of the keys since counting began.
+	Hits   int64    `json:"hits"`
+	Unused []string `json:"unused"`
+}
+
+// Report gets the keys of each loaded language not looked up in the window before now, or since counting
+// began if window is 0.
+func (u *KeyUsage) Report(st *loader.StringTable, window time.Duration) UsageReport {
+	report := UsageReport{Since: u.since, UnusedSince: u.since, Languages: []LanguageUsage{}}
+	if window > 0 {
+		if start := u.now().Add(-window); start.After(u.since) {
+			report.UnusedSince = start
+		}
+	}
+	cutoff := report.UnusedSince.UnixNano()
+
+	for _, tag := range st.Tags() {
+		cat, err := st.StringsByTag(tag)
+		if err != nil {
+			continue
+		}
+		lu := LanguageUsage{Language: tag.String(), Keys: len(cat.Strings), Unused: []string{}}
+		for _, key := range sortedKeys(cat.Strings) {
+			v, ok := u.counters.Load(usageID{key: key, lang: lu.Language})
+			if !ok {
+				lu.Unused = append(lu.Unused, key)
+				continue
+			}
+			c := v.(*usageCounter)
+			lu.Hits += c.hits.Load()
+			if c.last.Load() < cutoff {
+				lu.Unused = append(lu.Unused, key)
+				continue
+			}
+			lu.Requested++
+		}
+		report.Languages = append(report.Languages, lu)
+	}
+	return report
+}
+
+// ParseUsageWindow parses a time window as a duration, such as 36h, or a number of days, such as 30d.
+func ParseUsageWindow(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, errors.New("invalid window " + strconv.Quote(s))
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid window " + strconv.Quote(s))
+	}
+	return d, nil
+}
+
+// UsageHandler handles a request for the keys not looked up in the window param, such as 30d, or since
+// counting began. The language param narrows the report to one language.
+type UsageHandler struct {
+	ST    *loader.StringTable
+	Usage *KeyUsage
+}
+
+func (h UsageHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var window time.Duration
+	if w := req.URL.Query().Get("window"); w != "" {
+		var err error
+		if window, err = ParseUsageWindow(w); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("400 - " + err.Error()))
+			return
+		}
+	}
+
+	report := h.Usage.Report(h.ST, window)
+	if l := req.URL.Query().Get("language"); l != "" {
+		tag, err := language.Parse(l)
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("400 - Unknown language"))
+			return
+		}
+		languages := []LanguageUsage{}
+		for _, lu := range report.Languages {
+			if lu.Language == tag.String() {
+				languages = append(languages, lu)
+			}
+		}
+		report.Languages = languages
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	err := json.NewEncoder(res).Encode(report)
+	if err != nil {
+		log.Error().Err(err).Msg("Unexpected error writing key usage")
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestKeyUsageReport(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello", "goodbye", "Goodbye", "title", "Title"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour", "goodbye", "Au revoir"),
+	})
+	u, err := NewKeyUsage("", 0)
+	assert.Nil(t, err)
+	defer u.Close()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	u.now = func() time.Time { return now }
+	u.since = now
+
+	u.Hit("goodbye", language.MustParse("en-US"))
+	now = now.Add(48 * time.Hour)
+	u.Hit("hello", language.MustParse("en-US"))
+	u.Hit("hello", language.MustParse("en-US"))
+	u.Hit("hello", language.MustParse("fr-FR"))
+
+	report := u.Report(st, 0)
+	assert.Equal(t, now.Add(-48*time.Hour), report.UnusedSince)
+	assert.Equal(t, []LanguageUsage{
+		{Language: "en-US", Keys: 3, Requested: 2, Hits: 3, Unused: []string{"title"}},
+		{Language: "fr-FR", Keys: 2, Requested: 1, Hits: 1, Unused: []string{"goodbye"}},
+	}, report.Languages)
+
+	report = u.Report(st, 24*time.Hour)
+	assert.Equal(t, now.Add(-24*time.Hour), report.UnusedSince)
+	assert.Equal(t, []string{"goodbye", "title"}, report.Languages[0].Unused)
+
+	// A window from before counting began starts when it did.
+	report = u.Report(st, 30*24*time.Hour)
+	assert.Equal(t, report.Since, report.UnusedSince)
+	assert.Equal(t, []string{"title"}, report.Languages[0].Unused)
+}
+
+func TestKeyUsageSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	u, err := NewKeyUsage(path, 0)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				u.Hit("hello", language.MustParse("en-US"))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, u.Close())
+
+	loaded, err := LoadKeyUsage(path)
+	assert.Nil(t, err)
+	assert.Equal(t, u.since.UTC(), loaded.since.UTC())
+	v, ok := loaded.counters.Load(usageID{key: "hello", lang: "en-US"})
+	assert.True(t, ok)
+	assert.Equal(t, int64(800), v.(*usageCounter).hits.Load())
+
+	// Counting picks up where the saved counts left off.
+	resumed, err := NewKeyUsage(path, time.Millisecond)
+	assert.Nil(t, err)
+	resumed.Hit("hello", language.MustParse("en-US"))
+	assert.Nil(t, resumed.Close())
+	loaded, err = LoadKeyUsage(path)
+	assert.Nil(t, err)
+	v, _ = loaded.counters.Load(usageID{key: "hello", lang: "en-US"})
+	assert.Equal(t, int64(801), v.(*usageCounter).hits.Load())
+
+	_, err = LoadKeyUsage(filepath.Join(t.TempDir(), "none.json"))
+	assert.NotNil(t, err)
+}
+
+func TestUsageHandler(t *testing.T) {
+	st := newTestStringTable(t, fstest.MapFS{
+		"en-us/messages.json": gotextFile("en-us", "hello", "Hello", "goodbye", "Goodbye"),
+		"fr-fr/messages.json": gotextFile("fr-fr", "hello", "Bonjour"),
+	})
+	u, err := NewKeyUsage("", 0)
+	assert.Nil(t, err)
+	defer u.Close()
+
+	// Single and batch lookups of translated keys are counted.
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/strings/hello?lang=fr-fr", nil)
+	StringHandler{ST: st, Usage: u}.ServeHTTP(res, mux.SetURLVars(req, map[string]string{"str": "hello"}))
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/v1/strings:batch?lang=en-us", strings.NewReader(`{"keys": ["hello", "unknown"]}`))
+	BatchHandler{ST: st, Usage: u}.ServeHTTP(res, req)
+	// Lookups count under the loaded language they were served in, whatever extensions the request or match has.
+	for lang, key := range map[string]string{"fr-ca": "hello", "en-US-u-nu-thai": "goodbye"} {
+		res = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/v1/strings/"+key+"?lang="+lang, nil)
+		StringHandler{ST: st, Usage: u}.ServeHTTP(res, mux.SetURLVars(req, map[string]string{"str": key}))
+	}
+
+	h := UsageHandler{ST: st, Usage: u}
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/usage?window=30d", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	var report UsageReport
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&report))
+	assert.Equal(t, []LanguageUsage{
+		{Language: "en-US", Keys: 2, Requested: 2, Hits: 2, Unused: []string{}},
+		{Language: "fr-FR", Keys: 1, Requested: 1, Hits: 2, Unused: []string{}},
+	}, report.Languages)
+	var counted int
+	u.counters.Range(func(k, v interface{}) bool {
+		counted++
+		return true
+	})
+	assert.Equal(t, 3, counted)
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/usage?language=fr-fr", nil))
+	report = UsageReport{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&report))
+	assert.Len(t, report.Languages, 1)
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/v1/usage?window=soon", nil))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestParseUsageWindow(t *testing.T) {
+	d, err := ParseUsageWindow("30d")
+	assert.Nil(t, err)
+	assert.Equal(t, 30*24*time.Hour, d)
+	d, err = ParseUsageWindow("36h")
+	assert.Nil(t, err)
+	assert.Equal(t, 36*time.Hour, d)
+	_, err = ParseUsageWindow("-1d")
+	assert.NotNil(t, err)
+	_, err = ParseUsageWindow("d")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,124 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+)
+
+// arbLocaleKey is the ARB attribute that holds the language of the file.
+const arbLocaleKey = "@@locale"
+
+// ARBLoader loads strings from files in the Application Resource Bundle format used by Flutter.
+type ARBLoader struct {
+	catalogsByTagStr map[string]*StringCatalog
+	messageSetter
+}
+
+// NewARBLoader factory method.
+func NewARBLoader() *ARBLoader {
+	return &ARBLoader{
+		catalogsByTagStr: map[string]*StringCatalog{},
+	}
+}
+
+// StringsByTag gets the string table for the given language tag.
+func (ldr *ARBLoader) StringsByTag(tag language.Tag) (*StringCatalog, error) {
+	if cat, ok := ldr.catalogsByTagStr[tag.String()]; ok {
+		return cat, nil
+	}
+	return nil, errors.New("catalog not found for tag " + tag.String())
+}
+
+// NeedsTag implements the Loader interface.
+func (ldr *ARBLoader) NeedsTag() bool {
+	// Not needed because the langauge is usually embedded in the file.
+	return false
+}
+
+// ReadMessages implements the Loader interface.
+// The language is taken from the @@locale attribute, falling back to tag if the file has none.
+func (ldr *ARBLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	entries := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return err
+	}
+
+	var t language.Tag
+	if raw, ok := entries[arbLocaleKey]; ok {
+		var localeStr string
+		if err := json.Unmarshal(raw, &localeStr); err != nil {
+			return err
+		}
+		t, err = language.Parse(localeStr)
+		if err != nil {
+			return err
+		}
+	} else if tag != nil && *tag != language.Und {
+		t = *tag
+	} else {
+		return errors.New("ARB file has no " + arbLocaleKey + " attribute")
+	}
+
+	tagStr := t.String()
+	ldr.catalogsByTagStr[tagStr] = NewStringCatalog(modTime)
+
+	for id, raw := range entries {
+		// Attributes and resource metadata are prefixed with @.
+		if strings.HasPrefix(id, "@") {
+			continue
+		}
+
+		var translation string
+		if err := json.Unmarshal(raw, &translation); err != nil {
+			log.Warn().Str("languagetag", tagStr).Str("id", id).Err(err).Msg("Skipping non-string resource")
+			continue
+		}
+
+		log.Debug().Str("languagetag", tagStr).
+			Str("id", id).
+			Str("translation", translation).
+			Msg("Loading string")
+		ldr.setString(t, id, translation)
+
+		ldr.catalogsByTagStr[tagStr].Strings[id] = translation
+		if meta := arbMetadata(entries["@"+id]); len(meta) > 0 {
+			ldr.catalogsByTagStr[tagStr].Metadata[id] = meta
+		}
+	}
+
+	return nil
+}
+
+// arbResource is the part of a resource's @ attributes that is kept as metadata.
+type arbResource struct {
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context,omitempty"`
+}
+
+func arbMetadata(raw json.RawMessage) map[string]string {
+	var res arbResource
+	if raw == nil || json.Unmarshal(raw, &res) != nil {
+		return nil
+	}
+	meta := map[string]string{}
+	if res.Description != "" {
+		meta[MetadataComment] = res.Description
+	}
+	if res.Context != "" {
+		meta[MetadataContext] = res.Context
+	}
+	return meta
+}
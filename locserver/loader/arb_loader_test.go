@@ -0,0 +1,55 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestSimpleARBLoad(t *testing.T) {
+	data := `{
+		"@@locale": "en-us",
+		"foo": "foo2",
+		"@foo": {
+			"description": "A foo"
+		},
+		"bar": "bar2"
+	}`
+
+	reader := strings.NewReader(data)
+
+	loader := NewARBLoader()
+	err := loader.ReadMessages(reader, nil, time.Now())
+
+	assert.Nil(t, err)
+
+	// Test the translation
+	p := getPrinter("en-us")
+	assert.Equal(t, "foo2", p.Sprintf("foo"))
+	assert.Equal(t, "bar2", p.Sprintf("bar"))
+
+	// Test the catalog skips metadata
+	cat, err := loader.StringsByTag(language.MustParse("en-us"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"foo": "foo2", "bar": "bar2"}, cat.Strings)
+}
+
+func TestARBLoadTagFallback(t *testing.T) {
+	data := `{
+		"foo": "german foo"
+	}`
+
+	loader := NewARBLoader()
+	err := loader.ReadMessages(strings.NewReader(data), nil, time.Now())
+	assert.NotNil(t, err)
+
+	deTag := language.MustParse("de-de")
+	err = loader.ReadMessages(strings.NewReader(data), &deTag, time.Now())
+	assert.Nil(t, err)
+
+	p := getPrinter("de-de")
+	assert.Equal(t, "german foo", p.Sprintf("foo"))
+}
@@ -0,0 +1,208 @@
+package loader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// archiveSettleTime is how long the archive must go without changes before it is reloaded,
+// so that an archive that is still being written is not read.
+const archiveSettleTime = 250 * time.Millisecond
+
+var archiveExts = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether path names a locales archive that ArchiveSource can read.
+func IsArchive(path string) bool {
+	lower := strings.ToLower(path)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveSource reads a locales tree from a zip, tar, or gzipped tar archive, entirely in memory.
+type ArchiveSource struct {
+	Path string
+
+	watcher *fsnotify.Watcher
+	changed chan struct{}
+}
+
+// NewArchiveSource is a factory method for ArchiveSource.
+// If watch is true, the archive is reloaded whenever the file is written or replaced.
+func NewArchiveSource(archivePath string, watch bool) (*ArchiveSource, error) {
+	src := &ArchiveSource{
+		Path: archivePath,
+	}
+
+	if watch {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create watcher on %s: %v", archivePath, err)
+		}
+		// Watch the directory rather than the file, because replacing the file (e.g. with mv)
+		// ends a watch on the file itself.
+		err = watcher.Add(filepath.Dir(archivePath))
+		if err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch %s: %v", archivePath, err)
+		}
+		src.watcher = watcher
+		src.changed = make(chan struct{}, 1)
+		go src.watch()
+	}
+
+	return src, nil
+}
+
+// Open implements the Source interface.
+func (src *ArchiveSource) Open() (fs.FS, error) {
+	data, err := ioutil.ReadFile(src.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys, err := archiveToFS(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src.Path, err)
+	}
+	return fsys, nil
+}
+
+// Changed implements the Source interface.
+func (src *ArchiveSource) Changed() <-chan struct{} {
+	if src.changed == nil {
+		return nil
+	}
+	return src.changed
+}
+
+// Close implements the Source interface.
+func (src *ArchiveSource) Close() error {
+	if src.watcher == nil {
+		return nil
+	}
+	return src.watcher.Close()
+}
+
+func (src *ArchiveSource) watch() {
+	target := filepath.Clean(src.Path)
+	var settle *time.Timer
+	for {
+		select {
+		case event, ok := <-src.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != target || event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) == 0 {
+				continue
+			}
+			log.Debug().Str("name", event.Name).Uint32("op", uint32(event.Op)).Msg("Archive changed")
+			if settle == nil {
+				settle = time.AfterFunc(archiveSettleTime, func() { notify(src.changed) })
+			} else {
+				settle.Reset(archiveSettleTime)
+			}
+
+		case err, ok := <-src.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Error().Err(err).Str("path", src.Path).Msg("Error from archive watcher")
+		}
+	}
+}
+
+// archiveToFS reads a zip, tar, or gzipped tar archive into an in-memory file system.
+// The format is detected from the content.
+func archiveToFS(data []byte) (fs.FS, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06")) {
+		return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	return tarToFS(reader)
+}
+
+// tarToFS reads a tar stream into an in-memory file system.
+func tarToFS(reader io.Reader) (fs.FS, error) {
+	files := map[string]MemFile{}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = MemFile{Data: data, ModTime: hdr.ModTime}
+	}
+
+	return NewMemFS(files)
+}
+
+// MemFile is the contents of a file for NewMemFS.
+type MemFile struct {
+	Data    []byte
+	ModTime time.Time
+}
+
+// NewMemFS builds an in-memory file system from files keyed by path. It reuses the zip
+// file system from the standard library, which already knows how to list directories.
+func NewMemFS(files map[string]MemFile) (fs.FS, error) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+
+	for name, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     strings.TrimPrefix(path.Clean("/"+name), "/"),
+			Method:   zip.Store,
+			Modified: f.ModTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
@@ -0,0 +1,132 @@
+package loader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+			ModTime:  time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestIsArchive(t *testing.T) {
+	assert.True(t, IsArchive("translations-42.tar.gz"))
+	assert.True(t, IsArchive("/tmp/locales.ZIP"))
+	assert.True(t, IsArchive("locales.tgz"))
+	assert.False(t, IsArchive("./locales-po"))
+}
+
+func TestArchiveSourceFormats(t *testing.T) {
+	files := map[string]string{
+		"./en-us/messages.po": string(poFile("colour", "color").Data),
+		"en-gb/messages.po":   string(poFile("colour", "colour").Data),
+	}
+
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, data := range map[string][]byte{
+		"locales.zip":    zipArchive(t, map[string]string{"en-gb/messages.po": files["en-gb/messages.po"]}),
+		"locales.tar.gz": tarGzArchive(t, files),
+	} {
+		archivePath := filepath.Join(dir, name)
+		ioutil.WriteFile(archivePath, data, 0644)
+
+		src, err := NewArchiveSource(archivePath, false)
+		assert.Nil(t, err, name)
+		assert.Nil(t, src.Changed(), name)
+
+		st, err := NewStringTableSource(src, NewPOLoader())
+		assert.Nil(t, err, name)
+		err = st.Load()
+		assert.Nil(t, err, name)
+
+		cat, err := st.StringsByTag(language.MustParse("en-gb"))
+		assert.Nil(t, err, name)
+		assert.Equal(t, "colour", cat.Strings["colour"], name)
+		st.Close()
+	}
+}
+
+func TestArchiveSourceReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archivePath := filepath.Join(dir, "translations.tar.gz")
+	ioutil.WriteFile(archivePath, tarGzArchive(t, map[string]string{
+		"nl-nl/messages.po": string(poFile("greeting", "hallo").Data),
+	}), 0644)
+
+	src, err := NewArchiveSource(archivePath, true)
+	assert.Nil(t, err)
+	st, err := NewStringTableSource(src, NewPOLoader())
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+
+	// Replace the archive the way a deploy would: write elsewhere, then rename over it.
+	tmpPath := filepath.Join(dir, "next.tmp")
+	ioutil.WriteFile(tmpPath, tarGzArchive(t, map[string]string{
+		"nl-nl/messages.po": string(poFile("greeting", "goedendag").Data),
+		"nl-be/messages.po": string(poFile("greeting", "dag").Data),
+	}), 0644)
+	os.Rename(tmpPath, archivePath)
+
+	assert.Eventually(t, func() bool {
+		cat, err := st.StringsByTag(language.MustParse("nl-be"))
+		return err == nil && cat.Strings["greeting"] == "dag"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	cat, err := st.StringsByTag(language.MustParse("nl-nl"))
+	assert.Nil(t, err)
+	assert.Equal(t, "goedendag", cat.Strings["greeting"])
+}
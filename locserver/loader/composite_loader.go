@@ -0,0 +1,282 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+)
+
+// sniffLen is how much of a file is passed to FileMatchers for content sniffing.
+const sniffLen = 512
+
+// FileMatcher reports whether a loader can handle a file.
+// head holds up to the first 512 bytes of the file for content sniffing.
+// path may be empty if the file name is not known.
+type FileMatcher func(path string, head []byte) bool
+
+// MatchExtensions creates a FileMatcher that accepts files with any of the given extensions.
+// Extensions are compared case-insensitively, with or without their leading dot.
+func MatchExtensions(exts ...string) FileMatcher {
+	normalized := make([]string, 0, len(exts))
+	for _, e := range exts {
+		normalized = append(normalized, normalizeExtension(e))
+	}
+	return func(path string, head []byte) bool {
+		ext := strings.ToLower(filepath.Ext(path))
+		for _, e := range normalized {
+			if ext == e {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ParseExtensions parses a comma-separated list of file extensions for MatchExtensions, such as
+// "toml, .ini". The leading dot is optional.
+func ParseExtensions(list string) ([]string, error) {
+	exts := []string{}
+	for _, e := range strings.Split(list, ",") {
+		ext := normalizeExtension(e)
+		if ext == "." || strings.ContainsAny(ext[1:], `./\*? `) {
+			return nil, fmt.Errorf("invalid file extension %q", strings.TrimSpace(e))
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// MatchContent creates a FileMatcher that accepts files whose head contains marker.
+// If exts are given, the file must also have one of those extensions.
+func MatchContent(marker string, exts ...string) FileMatcher {
+	extMatcher := MatchExtensions(exts...)
+	return func(path string, head []byte) bool {
+		if len(exts) > 0 && !extMatcher(path, head) {
+			return false
+		}
+		return bytes.Contains(head, []byte(marker))
+	}
+}
+
+// MatchAny creates a FileMatcher that accepts files accepted by any of the given matchers.
+func MatchAny(matchers ...FileMatcher) FileMatcher {
+	return func(path string, head []byte) bool {
+		for _, m := range matchers {
+			if m(path, head) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FileReport records which member of a CompositeLoader handled a file.
+type FileReport struct {
+	Path string
+	// Loader is the name of the member loader, or empty if the file was ignored.
+	Loader string
+	Err    error
+}
+
+type compositeMember struct {
+	name   string
+	match  FileMatcher
+	loader Loader
+}
+
+// CompositeLoader dispatches each file to the first member loader whose FileMatcher accepts it,
+// so that a single locales tree can mix formats. Files no member accepts are ignored.
+type CompositeLoader struct {
+	members []compositeMember
+
+	mu      sync.Mutex
+	reports map[string]FileReport
+}
+
+// NewCompositeLoader factory method.
+func NewCompositeLoader() *CompositeLoader {
+	return &CompositeLoader{
+		reports: map[string]FileReport{},
+	}
+}
+
+// NewAutoLoader creates a CompositeLoader for every registered loader that has file matchers.
+func NewAutoLoader() *CompositeLoader {
+	ldr := NewCompositeLoader()
+	for _, r := range autoDetected() {
+		ldr.Add(r.name, r.match, r.factory())
+	}
+	return ldr
+}
+
+// Add appends a member loader. Members are tried in the order they were added.
+func (ldr *CompositeLoader) Add(name string, match FileMatcher, member Loader) {
+	ldr.members = append(ldr.members, compositeMember{
+		name:   name,
+		match:  match,
+		loader: member,
+	})
+}
+
+// StringsByTag merges the string tables of every member that has the given language tag.
+func (ldr *CompositeLoader) StringsByTag(tag language.Tag) (*StringCatalog, error) {
+	var merged *StringCatalog
+	seen := map[Loader]bool{}
+	for _, m := range ldr.members {
+		if seen[m.loader] {
+			continue
+		}
+		seen[m.loader] = true
+
+		cat, err := m.loader.StringsByTag(tag)
+		if err != nil {
+			continue
+		}
+		if merged == nil {
+			merged = NewStringCatalog(cat.LastModTime)
+		} else if cat.LastModTime.After(merged.LastModTime) {
+			merged.LastModTime = cat.LastModTime
+		}
+		for k, v := range cat.Strings {
+			merged.Strings[k] = v
+		}
+		for k, v := range cat.Metadata {
+			merged.Metadata[k] = v
+		}
+	}
+
+	if merged == nil {
+		return nil, errors.New("catalog not found for tag " + tag.String())
+	}
+	return merged, nil
+}
+
+// NeedsTag implements the Loader interface.
+func (ldr *CompositeLoader) NeedsTag() bool {
+	// Needed if any of the members need it, because we don't know which one will handle a file.
+	for _, m := range ldr.members {
+		if m.loader.NeedsTag() {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadMessages implements the Loader interface.
+// Without a file name, the member is chosen by content sniffing only.
+func (ldr *CompositeLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	return ldr.ReadFile("", reader, tag, modTime)
+}
+
+// ReadFile implements the FileLoader interface.
+func (ldr *CompositeLoader) ReadFile(path string, reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	br := bufio.NewReaderSize(reader, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	for _, m := range ldr.members {
+		if !m.match(path, head) {
+			continue
+		}
+
+		log.Debug().Str("path", path).Str("loader", m.name).Msg("Dispatching file")
+		if fl, ok := m.loader.(FileLoader); ok {
+			err = fl.ReadFile(path, br, tag, modTime)
+		} else {
+			err = m.loader.ReadMessages(br, tag, modTime)
+		}
+		ldr.record(FileReport{Path: path, Loader: m.name, Err: err})
+		return err
+	}
+
+	log.Debug().Str("path", path).Msg("Ignoring file with unknown format")
+	ldr.record(FileReport{Path: path})
+	return nil
+}
+
+// EditFile implements the FileEditor interface, using the member that would read the file.
+func (ldr *CompositeLoader) EditFile(path string, data []byte, tag language.Tag, edits []Edit, add bool) ([]byte, []Edit, error) {
+	head := data
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	for _, m := range ldr.members {
+		if !m.match(path, head) {
+			continue
+		}
+		if fe, ok := m.loader.(FileEditor); ok {
+			return fe.EditFile(path, data, tag, edits, add)
+		}
+		return nil, nil, fmt.Errorf("%w: %s is read by %s", ErrNotEditable, path, m.name)
+	}
+	return nil, nil, fmt.Errorf("%w: %s has an unknown format", ErrNotEditable, path)
+}
+
+// isolate removes the members that can't be isolated, so that their files are ignored.
+func (ldr *CompositeLoader) isolate() bool {
+	members := []compositeMember{}
+	for _, m := range ldr.members {
+		if Isolate(m.loader) {
+			members = append(members, m)
+		}
+	}
+	ldr.members = members
+	return len(members) > 0
+}
+
+func (ldr *CompositeLoader) languages() []language.Tag {
+	tags := []language.Tag{}
+	seen := map[language.Tag]bool{}
+	for _, m := range ldr.members {
+		if i, ok := m.loader.(isolator); ok {
+			for _, t := range i.languages() {
+				if !seen[t] {
+					seen[t] = true
+					tags = append(tags, t)
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// Report gets the outcome of the most recent load of every file, sorted by path.
+func (ldr *CompositeLoader) Report() []FileReport {
+	ldr.mu.Lock()
+	defer ldr.mu.Unlock()
+
+	reports := make([]FileReport, 0, len(ldr.reports))
+	for _, r := range ldr.reports {
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Path < reports[j].Path
+	})
+	return reports
+}
+
+func (ldr *CompositeLoader) record(r FileReport) {
+	ldr.mu.Lock()
+	defer ldr.mu.Unlock()
+	ldr.reports[r.Path] = r
+}
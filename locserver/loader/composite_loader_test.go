@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestCompositeLoadMixedFormats(t *testing.T) {
+	poData := header + "\n" + "msgid \"foo\"\nmsgstr \"french foo\"\n"
+
+	xlfData := `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en-us" trgLang="fr-fr">
+	<file id="fr-fr">
+	 <unit>
+	  <segment id="bar">
+	   <source>bar</source>
+	   <target>french bar</target>
+	  </segment>
+	 </unit>
+	</file>
+   </xliff>`
+
+	arbData := `{"@@locale": "fr-fr", "baz": "french baz"}`
+
+	gotextData := `{"language": "fr-fr", "messages": [{"id": "qux", "translation": "french qux"}]}`
+
+	loader := NewAutoLoader()
+	assert.True(t, loader.NeedsTag())
+
+	frTag := language.MustParse("fr-fr")
+	files := map[string]string{
+		"fr-fr/a.po":         poData,
+		"fr-fr/b.xlf":        xlfData,
+		"fr-fr/c.json":       arbData,
+		"fr-fr/d.json":       gotextData,
+		"fr-fr/README.md":    "not a catalog",
+		"fr-fr/messages.mo":  string(moFile(binary.LittleEndian, "quux", "french quux")),
+		"fr-fr/strings.yaml": "corge: french corge",
+	}
+	for path, data := range files {
+		err := loader.ReadFile(path, strings.NewReader(data), &frTag, time.Now())
+		assert.Nil(t, err, path)
+	}
+
+	cat, err := loader.StringsByTag(frTag)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"foo":   "french foo",
+		"bar":   "french bar",
+		"baz":   "french baz",
+		"qux":   "french qux",
+		"quux":  "french quux",
+		"corge": "french corge",
+	}, cat.Strings)
+
+	handledBy := map[string]string{}
+	for _, r := range loader.Report() {
+		assert.Nil(t, r.Err)
+		handledBy[r.Path] = r.Loader
+	}
+	assert.Equal(t, map[string]string{
+		"fr-fr/README.md":    "",
+		"fr-fr/a.po":         "po",
+		"fr-fr/b.xlf":        "xliff2",
+		"fr-fr/c.json":       "arb",
+		"fr-fr/d.json":       "gotext",
+		"fr-fr/messages.mo":  "mo",
+		"fr-fr/strings.yaml": "yaml",
+	}, handledBy)
+}
+
+func TestCompositeLoadReportsErrors(t *testing.T) {
+	loader := NewAutoLoader()
+	err := loader.ReadFile("en-us/broken.json", strings.NewReader("{"), nil, time.Now())
+	assert.NotNil(t, err)
+
+	report := loader.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "gotext", report[0].Loader)
+	assert.Equal(t, err, report[0].Err)
+}
+
+func TestCompositeSniffWithoutPath(t *testing.T) {
+	loader := NewCompositeLoader()
+	loader.Add("arb", MatchContent(`"@@locale"`), NewARBLoader())
+
+	err := loader.ReadMessages(strings.NewReader(`{"@@locale": "it-it", "foo": "italian foo"}`), nil, time.Now())
+	assert.Nil(t, err)
+
+	p := getPrinter("it-it")
+	assert.Equal(t, "italian foo", p.Sprintf("foo"))
+
+	_, err = loader.StringsByTag(language.MustParse("es-es"))
+	assert.NotNil(t, err)
+}
+
+func TestMatchExtensions(t *testing.T) {
+	match := MatchExtensions("toml", " .INI ")
+	assert.True(t, match("en-us/strings.toml", nil))
+	assert.True(t, match("en-us/strings.ini", nil))
+	assert.False(t, match("en-us/strings.json", nil))
+	assert.False(t, match("en-us/toml", nil))
+}
+
+func TestParseExtensions(t *testing.T) {
+	exts, err := ParseExtensions("toml, .ini,.Props")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{".toml", ".ini", ".props"}, exts)
+
+	for _, bad := range []string{"", "toml,", "toml,,ini", ".", "tar.gz", "*.toml", "a b"} {
+		_, err := ParseExtensions(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
@@ -0,0 +1,59 @@
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// ErrUnknownFormat is returned when no registered loader matches a file.
+var ErrUnknownFormat = errors.New("unknown locale file format")
+
+// ReadCatalogs reads one locale file with the registered loader that matches it, without serving its strings.
+// tag is the language for formats that don't name one. It returns the catalog of every language in the file.
+func ReadCatalogs(path string, data []byte, tag *language.Tag) (map[language.Tag]*StringCatalog, error) {
+	return readCatalogs(NewAutoLoader(), path, data, tag)
+}
+
+// ReadUploadedCatalogs is ReadCatalogs for a file a client sent, named as the client chose. Loaders that run
+// a command on the file, such as ExecLoader, are left out, so that nothing a client sends reaches a command line.
+func ReadUploadedCatalogs(name string, data []byte, tag *language.Tag) (map[language.Tag]*StringCatalog, error) {
+	ldr := NewCompositeLoader()
+	for _, r := range autoDetected() {
+		member := r.factory()
+		if _, ok := member.(*ExecLoader); ok {
+			continue
+		}
+		ldr.Add(r.name, r.match, member)
+	}
+	return readCatalogs(ldr, name, data, tag)
+}
+
+func readCatalogs(ldr *CompositeLoader, path string, data []byte, tag *language.Tag) (map[language.Tag]*StringCatalog, error) {
+	if !Isolate(ldr) {
+		return nil, errors.New("registered loaders can't read files without serving them")
+	}
+
+	if err := ldr.ReadFile(path, bytes.NewReader(data), tag, time.Now()); err != nil {
+		return nil, err
+	}
+	if report := ldr.Report(); len(report) == 0 || report[0].Loader == "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+
+	cats := map[language.Tag]*StringCatalog{}
+	tags := ldr.languages()
+	if tag != nil && *tag != language.Und {
+		// A file without any translated strings still has an empty catalog.
+		tags = append(tags, *tag)
+	}
+	for _, t := range tags {
+		if cat, err := ldr.StringsByTag(t); err == nil {
+			cats[t] = cat
+		}
+	}
+	return cats, nil
+}
@@ -0,0 +1,88 @@
+package loader
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+)
+
+// CSVLoader loads strings from CSV files of keys and values, like the ones served at /v1/strings.
+// If the first row is a header starting with "key,value", any other columns are read as metadata.
+type CSVLoader struct {
+	catalogsByTagStr map[string]*StringCatalog
+	messageSetter
+}
+
+// NewCSVLoader factory method.
+func NewCSVLoader() *CSVLoader {
+	return &CSVLoader{
+		catalogsByTagStr: map[string]*StringCatalog{},
+	}
+}
+
+// StringsByTag gets the string table for the given language tag.
+func (ldr *CSVLoader) StringsByTag(tag language.Tag) (*StringCatalog, error) {
+	if cat, ok := ldr.catalogsByTagStr[tag.String()]; ok {
+		return cat, nil
+	}
+	return nil, errors.New("catalog not found for tag " + tag.String())
+}
+
+// NeedsTag implements the Loader interface.
+func (ldr *CSVLoader) NeedsTag() bool {
+	// Needed because the language is not in the file.
+	return true
+}
+
+// ReadMessages implements the Loader interface.
+func (ldr *CSVLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	if tag == nil {
+		return errors.New("tag string is required by CSV loader")
+	}
+
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	var columns []string
+	if len(rows) > 0 && len(rows[0]) >= 2 && rows[0][0] == "key" && rows[0][1] == "value" {
+		columns, rows = rows[0], rows[1:]
+	}
+
+	tagStr := tag.String()
+	cat := NewStringCatalog(modTime)
+	for i, row := range rows {
+		if len(row) < 2 {
+			return fmt.Errorf("row %d has no value", i+1)
+		}
+
+		id, translation := row[0], row[1]
+		log.Debug().Str("languagetag", tagStr).
+			Str("id", id).
+			Str("translation", translation).
+			Msg("Loading string")
+		ldr.setString(*tag, id, translation)
+		cat.Strings[id] = translation
+
+		meta := map[string]string{}
+		for c := 2; c < len(row) && c < len(columns); c++ {
+			if row[c] != "" {
+				meta[columns[c]] = row[c]
+			}
+		}
+		if len(meta) > 0 {
+			cat.Metadata[id] = meta
+		}
+	}
+	ldr.catalogsByTagStr[tagStr] = cat
+
+	return nil
+}
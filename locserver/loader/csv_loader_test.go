@@ -0,0 +1,31 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestCSVLoad(t *testing.T) {
+	ldr := NewCSVLoader()
+	tag := language.MustParse("hu-hu")
+
+	err := ldr.ReadMessages(strings.NewReader("greeting,szia\nfarewell,\"viszlát, később\"\n"), &tag, time.Now())
+	assert.Nil(t, err)
+	cat, err := ldr.StringsByTag(tag)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"greeting": "szia", "farewell": "viszlát, később"}, cat.Strings)
+	assert.Equal(t, "szia", getPrinter("hu-hu").Sprintf("greeting"))
+
+	err = ldr.ReadMessages(strings.NewReader("key,value,comment\ngreeting,szia,informal\nfarewell,viszlát,\n"), &tag, time.Now())
+	assert.Nil(t, err)
+	cat, _ = ldr.StringsByTag(tag)
+	assert.Equal(t, map[string]string{"greeting": "szia", "farewell": "viszlát"}, cat.Strings)
+	assert.Equal(t, map[string]map[string]string{"greeting": {MetadataComment: "informal"}}, cat.Metadata)
+
+	err = ldr.ReadMessages(strings.NewReader("greeting\n"), &tag, time.Now())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,97 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+)
+
+// DefaultExecTimeout is how long an ExecLoader waits for its command if no timeout is configured.
+const DefaultExecTimeout = 30 * time.Second
+
+// ExecLoader loads strings by running an external command once per file.
+//
+// The command is run with Args followed by the path of the file (if known), and gets the
+// file contents on stdin. It must write the messages to stdout in the format read by RecordsLoader.
+// A non-zero exit status fails the file, and anything written to stderr is included in the error.
+type ExecLoader struct {
+	*RecordsLoader
+
+	Command string
+	Args    []string
+	Timeout time.Duration
+}
+
+// NewExecLoader factory method.
+func NewExecLoader(command string, args []string, timeout time.Duration) *ExecLoader {
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	return &ExecLoader{
+		RecordsLoader: NewRecordsLoader(),
+		Command:       command,
+		Args:          args,
+		Timeout:       timeout,
+	}
+}
+
+// ReadMessages implements the Loader interface.
+func (ldr *ExecLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	return ldr.ReadFile("", reader, tag, modTime)
+}
+
+// ReadFile implements the FileLoader interface.
+func (ldr *ExecLoader) ReadFile(path string, reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	if ldr.Command == "" {
+		return errors.New("exec loader requires a command")
+	}
+
+	out, err := ldr.run(path, reader)
+	if err != nil {
+		return err
+	}
+
+	err = ldr.RecordsLoader.ReadMessages(bytes.NewReader(out), tag, modTime)
+	if err != nil {
+		return fmt.Errorf("%s: %v", ldr.Command, err)
+	}
+	return nil
+}
+
+func (ldr *ExecLoader) run(path string, stdin io.Reader) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ldr.Timeout)
+	defer cancel()
+
+	args := append([]string{}, ldr.Args...)
+	if path != "" {
+		args = append(args, path)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ldr.Command, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Debug().Str("command", ldr.Command).Strs("args", args).Msg("Running loader command")
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v", ldr.Timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s %s: %v: %s", ldr.Command, path, err, msg)
+		}
+		return nil, fmt.Errorf("%s %s: %v", ldr.Command, path, err)
+	}
+
+	return stdout.Bytes(), nil
+}
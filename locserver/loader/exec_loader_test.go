@@ -0,0 +1,137 @@
+package loader
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+// writeScript creates an executable shell script in a new temp directory and returns its path.
+// Callers remove the directory when done.
+func writeScript(t *testing.T, body string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec loader tests need a POSIX shell")
+	}
+
+	dir, err := ioutil.TempDir("", "exec-loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "loader.sh")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\n"+body), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestSimpleExecLoad(t *testing.T) {
+	script := writeScript(t, `
+echo '{"tag": "en-us", "key": "foo", "value": "foo2", "metadata": {"comment": "the foo"}}'
+echo '{"key": "bar", "value": "'"$(cat)"'"}'
+`)
+	defer os.RemoveAll(filepath.Dir(script))
+
+	loader := NewExecLoader(script, nil, time.Second)
+	assert.True(t, loader.NeedsTag())
+
+	enTag := language.MustParse("en-us")
+	err := loader.ReadFile("en-us/strings.custom", strings.NewReader("bar2"), &enTag, time.Now())
+	assert.Nil(t, err)
+
+	// Test the translation
+	p := getPrinter("en-us")
+	assert.Equal(t, "foo2", p.Sprintf("foo"))
+	assert.Equal(t, "bar2", p.Sprintf("bar"))
+
+	cat, err := loader.StringsByTag(enTag)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"comment": "the foo"}, cat.Metadata["foo"])
+}
+
+func TestExecLoadPassesPath(t *testing.T) {
+	script := writeScript(t, `echo '{"tag": "fr-fr", "key": "path", "value": "'"$2"'"}'`)
+	defer os.RemoveAll(filepath.Dir(script))
+
+	loader := NewExecLoader(script, []string{"--format"}, time.Second)
+	err := loader.ReadFile("fr-fr/strings.custom", strings.NewReader(""), nil, time.Now())
+	assert.Nil(t, err)
+
+	cat, err := loader.StringsByTag(language.MustParse("fr-fr"))
+	assert.Nil(t, err)
+	assert.Equal(t, "fr-fr/strings.custom", cat.Strings["path"])
+}
+
+func TestExecLoadCapturesStderr(t *testing.T) {
+	script := writeScript(t, `
+echo "cannot parse line 3" >&2
+exit 2
+`)
+	defer os.RemoveAll(filepath.Dir(script))
+
+	loader := NewExecLoader(script, nil, time.Second)
+	err := loader.ReadFile("en-us/broken.custom", strings.NewReader(""), nil, time.Now())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "exit status 2")
+	assert.Contains(t, err.Error(), "cannot parse line 3")
+}
+
+func TestExecLoadTimeout(t *testing.T) {
+	script := writeScript(t, `exec sleep 5`)
+	defer os.RemoveAll(filepath.Dir(script))
+
+	loader := NewExecLoader(script, nil, 100*time.Millisecond)
+	err := loader.ReadFile("en-us/slow.custom", strings.NewReader(""), nil, time.Now())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+}
+
+func TestExecLoadBadRecordKeepsCatalog(t *testing.T) {
+	good := writeScript(t, `echo '{"tag": "de-de", "key": "foo", "value": "german foo"}'`)
+	defer os.RemoveAll(filepath.Dir(good))
+	bad := writeScript(t, `
+echo '{"tag": "de-de", "key": "foo", "value": "partial"}'
+echo 'not json'
+`)
+	defer os.RemoveAll(filepath.Dir(bad))
+
+	deTag := language.MustParse("de-de")
+	loader := NewExecLoader(good, nil, time.Second)
+	err := loader.ReadFile("de-de/a.custom", strings.NewReader(""), &deTag, time.Now())
+	assert.Nil(t, err)
+
+	loader.Command = bad
+	err = loader.ReadFile("de-de/a.custom", strings.NewReader(""), &deTag, time.Now())
+	assert.NotNil(t, err)
+
+	cat, _ := loader.StringsByTag(deTag)
+	assert.Equal(t, "german foo", cat.Strings["foo"])
+	assert.Equal(t, "german foo", getPrinter("de-de").Sprintf("foo"))
+}
+
+func TestReadUploadedCatalogsSkipsExec(t *testing.T) {
+	script := writeScript(t, `echo '{"key": "foo", "value": "'"$1"'"}'`)
+	defer os.RemoveAll(filepath.Dir(script))
+	Register("testexec", func() Loader { return NewExecLoader(script, nil, time.Second) }, MatchExtensions(".custom"))
+	t.Cleanup(func() { unregister("testexec") })
+
+	enTag := language.MustParse("en-us")
+	cats, err := ReadCatalogs("strings.custom", []byte("foo2"), &enTag)
+	assert.Nil(t, err)
+	assert.Equal(t, "strings.custom", cats[enTag].Strings["foo"])
+
+	_, err = ReadUploadedCatalogs("strings.custom", []byte("foo2"), &enTag)
+	assert.True(t, errors.Is(err, ErrUnknownFormat))
+
+	cats, err = ReadUploadedCatalogs("strings.po", []byte(header+"\nmsgid \"foo\"\nmsgstr \"foo2\"\n"), &enTag)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo2", cats[enTag].Strings["foo"])
+}
@@ -0,0 +1,223 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GitSource reads a locales tree straight from the object database of a git repository, bare
+// or cloned, at a branch, tag, or commit. No working checkout is needed. It polls the ref and
+// reloads when it moves. It needs the git executable on the PATH.
+type GitSource struct {
+	Repo string
+	Ref  string
+	// Subdir is the directory within the repository that holds the locales, if not the root.
+	Subdir   string
+	Interval time.Duration
+
+	mu       sync.Mutex
+	snapshot fs.FS
+	commit   string
+	served   string
+
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// NewGitSource is a factory method for GitSource. It reads the tree at ref once, failing if
+// that doesn't succeed, and then polls every interval for the ref to move. An interval of 0
+// disables polling.
+func NewGitSource(repo, ref, subdir string, interval time.Duration) (*GitSource, error) {
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid ref %q", ref)
+	}
+
+	src := &GitSource{
+		Repo:     repo,
+		Ref:      ref,
+		Subdir:   strings.Trim(subdir, "/"),
+		Interval: interval,
+		done:     make(chan struct{}),
+	}
+
+	if _, err := src.sync(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		src.changed = make(chan struct{}, 1)
+		go src.poll()
+	}
+	return src, nil
+}
+
+// Open implements the Source interface.
+func (src *GitSource) Open() (fs.FS, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.served = src.commit
+	return src.snapshot, nil
+}
+
+// Revision implements the Versioned interface. It is the hash of the commit being served.
+func (src *GitSource) Revision() string {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.served
+}
+
+// Changed implements the Source interface.
+func (src *GitSource) Changed() <-chan struct{} {
+	if src.changed == nil {
+		return nil
+	}
+	return src.changed
+}
+
+// Close implements the Source interface.
+func (src *GitSource) Close() error {
+	close(src.done)
+	return nil
+}
+
+func (src *GitSource) poll() {
+	for {
+		select {
+		case <-src.done:
+			return
+		case <-time.After(src.Interval):
+		}
+
+		updated, err := src.sync()
+		if err != nil {
+			log.Warn().Str("repo", src.Repo).Str("ref", src.Ref).Err(err).Msg("Error polling for locales, keeping last snapshot")
+			continue
+		}
+		if updated {
+			notify(src.changed)
+		}
+	}
+}
+
+// sync reads the tree if the ref has moved, and reports whether it had.
+func (src *GitSource) sync() (bool, error) {
+	out, err := src.git(nil, "rev-parse", "--verify", "--quiet", src.Ref+"^{commit}")
+	if err != nil {
+		return false, fmt.Errorf("resolving %s: %v", src.Ref, err)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	src.mu.Lock()
+	current := src.commit
+	src.mu.Unlock()
+	if commit == current {
+		return false, nil
+	}
+
+	fsys, err := src.readTree(commit)
+	if err != nil {
+		return false, err
+	}
+
+	log.Info().Str("repo", src.Repo).Str("ref", src.Ref).Str("commit", commit).Msg("Read locales from git")
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.commit = commit
+	src.snapshot = fsys
+	return true, nil
+}
+
+func (src *GitSource) readTree(commit string) (fs.FS, error) {
+	out, err := src.git(nil, "show", "-s", "--format=%ct", commit)
+	if err != nil {
+		return nil, err
+	}
+	secs, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	modTime := time.Unix(secs, 0)
+
+	treeish := commit
+	if src.Subdir != "" {
+		treeish += ":" + src.Subdir
+	}
+	out, err = src.git(nil, "ls-tree", "-r", "-z", treeish)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each entry is "<mode> <type> <object>\t<path>".
+	paths := []string{}
+	objects := &bytes.Buffer{}
+	for _, entry := range strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+		tab := strings.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(entry[:tab])
+		if len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		paths = append(paths, entry[tab+1:])
+		objects.WriteString(fields[2] + "\n")
+	}
+
+	out, err = src.git(objects, "cat-file", "--batch")
+	if err != nil {
+		return nil, err
+	}
+
+	// Each blob is "<object> <type> <size>\n<contents>\n".
+	files := map[string]MemFile{}
+	reader := bufio.NewReader(bytes.NewReader(out))
+	for _, p := range paths {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", p, err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("reading %s: unexpected header %q", p, header)
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", p, err)
+		}
+
+		data := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("reading %s: %v", p, err)
+		}
+		files[p] = MemFile{Data: data[:size], ModTime: modTime}
+	}
+
+	return NewMemFS(files)
+}
+
+func (src *GitSource) git(stdin io.Reader, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", src.Repo}, args...)...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %v: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
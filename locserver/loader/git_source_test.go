@@ -0,0 +1,147 @@
+package loader
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+// gitRepo is a working clone that pushes to a bare repository, like a translation team would.
+type gitRepo struct {
+	t    *testing.T
+	dir  string
+	work string
+	bare string
+}
+
+func newGitRepo(t *testing.T) *gitRepo {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "git-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &gitRepo{
+		t:    t,
+		dir:  dir,
+		work: filepath.Join(dir, "work"),
+		bare: filepath.Join(dir, "translations.git"),
+	}
+	r.git(dir, "init", "--bare", "-b", "main", r.bare)
+	r.git(dir, "clone", r.bare, r.work)
+	return r
+}
+
+func (r *gitRepo) git(dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files into the working clone, commits, pushes, and returns the commit hash.
+func (r *gitRepo) commit(files map[string]string) string {
+	for name, data := range files {
+		p := filepath.Join(r.work, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		ioutil.WriteFile(p, []byte(data), 0644)
+	}
+	r.git(r.work, "add", "-A")
+	r.git(r.work, "commit", "-m", "update translations")
+	r.git(r.work, "push", "origin", "HEAD:main")
+	return r.git(r.work, "rev-parse", "HEAD")
+}
+
+func TestGitSourceFollowsRef(t *testing.T) {
+	repo := newGitRepo(t)
+	defer os.RemoveAll(repo.dir)
+
+	first := repo.commit(map[string]string{
+		"locales/pl-pl/messages.po": string(poFile("greeting", "cześć").Data),
+		"README.md":                 "not a locale",
+	})
+
+	src, err := NewGitSource(repo.bare, "main", "locales", 20*time.Millisecond)
+	assert.Nil(t, err)
+
+	st, err := NewStringTableSource(src, NewPOLoader())
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+
+	assert.Equal(t, first, st.Revision())
+	cat, err := st.StringsByTag(language.MustParse("pl-pl"))
+	assert.Nil(t, err)
+	assert.Equal(t, "cześć", cat.Strings["greeting"])
+
+	second := repo.commit(map[string]string{
+		"locales/pl-pl/messages.po": string(poFile("greeting", "dzień dobry").Data),
+	})
+	assert.Eventually(t, func() bool {
+		return st.Revision() == second
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cat, err = st.StringsByTag(language.MustParse("pl-pl"))
+	assert.Nil(t, err)
+	assert.Equal(t, "dzień dobry", cat.Strings["greeting"])
+}
+
+func TestGitSourcePinnedToTag(t *testing.T) {
+	repo := newGitRepo(t)
+	defer os.RemoveAll(repo.dir)
+
+	tagged := repo.commit(map[string]string{
+		"cs-cz/messages.po": string(poFile("greeting", "ahoj").Data),
+	})
+	repo.git(repo.work, "tag", "v1")
+	repo.git(repo.work, "push", "origin", "v1")
+	repo.commit(map[string]string{
+		"cs-cz/messages.po": string(poFile("greeting", "dobrý den").Data),
+	})
+
+	src, err := NewGitSource(repo.bare, "v1", "", 0)
+	assert.Nil(t, err)
+	assert.Nil(t, src.Changed())
+
+	fsys, err := src.Open()
+	assert.Nil(t, err)
+	assert.Equal(t, tagged, src.Revision())
+
+	data, err := ioutil.ReadAll(mustOpen(t, fsys, "cs-cz/messages.po"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "ahoj")
+}
+
+func TestGitSourceBadRef(t *testing.T) {
+	repo := newGitRepo(t)
+	defer os.RemoveAll(repo.dir)
+	repo.commit(map[string]string{"en-us/messages.po": string(poFile("a", "b").Data)})
+
+	_, err := NewGitSource(repo.bare, "no-such-branch", "", 0)
+	assert.NotNil(t, err)
+
+	_, err = NewGitSource(repo.bare, "--upload-pack=evil", "", 0)
+	assert.NotNil(t, err)
+}
+
+func mustOpen(t *testing.T, fsys fs.FS, name string) fs.File {
+	f, err := fsys.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
@@ -1,21 +1,95 @@
 package loader
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
 type langmessage struct {
-	ID          string `json:"id"`
-	Message     string `json:"message"`
-	Translation string `json:"translation"`
+	ID                string            `json:"id"`
+	Message           string            `json:"message"`
+	Translation       gotextTranslation `json:"translation"`
+	Meaning           string            `json:"meaning,omitempty"`
+	Comment           string            `json:"comment,omitempty"`
+	TranslatorComment string            `json:"translatorComment,omitempty"`
+}
+
+// gotextTranslation is either a string or a select on plural forms:
+//
+//	{"select": {"feature": "plural", "arg": "Count", "cases": {"one": {"msg": "1 file"}, "other": "%d files"}}}
+type gotextTranslation struct {
+	Msg   string
+	Arg   string
+	Cases map[string]string
+}
+
+type gotextSelect struct {
+	Select struct {
+		Feature string                     `json:"feature"`
+		Arg     string                     `json:"arg,omitempty"`
+		Cases   map[string]json.RawMessage `json:"cases"`
+	} `json:"select"`
+}
+
+type gotextCase struct {
+	Msg string `json:"msg"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (tr *gotextTranslation) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &tr.Msg); err == nil {
+		return nil
+	}
+
+	var sel gotextSelect
+	if err := json.Unmarshal(data, &sel); err != nil {
+		return err
+	}
+	if sel.Select.Feature != "plural" {
+		return errors.New("unsupported select feature " + sel.Select.Feature)
+	}
+	tr.Arg = sel.Select.Arg
+	tr.Cases = map[string]string{}
+	for category, raw := range sel.Select.Cases {
+		var c gotextCase
+		if err := json.Unmarshal(raw, &c.Msg); err != nil {
+			if err := json.Unmarshal(raw, &c); err != nil {
+				return err
+			}
+		}
+		tr.Cases[category] = c.Msg
+	}
+	tr.Msg = tr.Cases["other"]
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (tr gotextTranslation) MarshalJSON() ([]byte, error) {
+	if tr.Cases == nil {
+		return marshalJSON(tr.Msg)
+	}
+
+	var sel gotextSelect
+	sel.Select.Feature = "plural"
+	sel.Select.Arg = tr.Arg
+	sel.Select.Cases = map[string]json.RawMessage{}
+	for category, msg := range tr.Cases {
+		c, err := marshalJSON(gotextCase{Msg: msg})
+		if err != nil {
+			return nil, err
+		}
+		sel.Select.Cases[category] = c
+	}
+	return marshalJSON(sel)
 }
 
 type langmessages struct {
@@ -26,6 +100,7 @@ type langmessages struct {
 // GoTextJSONLoader loads strings from files in the JSON format supported by gotext.
 type GoTextJSONLoader struct {
 	catalogsByTagStr map[string]*StringCatalog
+	messageSetter
 }
 
 // NewGoTextJSONLoader factory method.
@@ -75,12 +150,283 @@ func (ldr *GoTextJSONLoader) ReadMessages(reader io.Reader, tag *language.Tag, m
 	for _, m := range lm.Messages {
 		log.Debug().Str("languagetag", tagStr).
 			Str("id", m.ID).
-			Str("translation", m.Translation).
+			Str("translation", m.Translation.Msg).
 			Msg("Loading string")
-		message.SetString(t, m.ID, m.Translation)
+		ldr.setString(t, m.ID, m.Translation.Msg)
 
-		ldr.catalogsByTagStr[tagStr].Strings[m.ID] = m.Translation
+		ldr.catalogsByTagStr[tagStr].Strings[m.ID] = m.Translation.Msg
+		if meta := m.metadata(); len(meta) > 0 {
+			ldr.catalogsByTagStr[tagStr].Metadata[m.ID] = meta
+		}
 	}
 
 	return nil
 }
+
+func (m langmessage) metadata() map[string]string {
+	meta := map[string]string{}
+	for key, value := range map[string]string{
+		MetadataComment:           m.Comment,
+		MetadataTranslatorComment: m.TranslatorComment,
+		MetadataContext:           m.Meaning,
+		MetadataPluralArg:         m.Translation.Arg,
+	} {
+		if value != "" {
+			meta[key] = value
+		}
+	}
+	for category, msg := range m.Translation.Cases {
+		meta[MetadataPluralPrefix+category] = msg
+	}
+	return meta
+}
+
+// EditFile implements the FileEditor interface.
+// Only the edited messages change, and only their translations; the rest of the file is kept byte for byte.
+// Messages with plural forms can be deleted but not edited. New messages go at the end, indented like the others.
+func (ldr *GoTextJSONLoader) EditFile(path string, data []byte, tag language.Tag, edits []Edit, add bool) ([]byte, []Edit, error) {
+	fields, closing, err := jsonFields(data, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var msgs *jsonSpan
+	for i := range fields {
+		if fields[i].key == "messages" {
+			msgs = &fields[i]
+		}
+	}
+	if msgs == nil && !add {
+		return data, edits, nil
+	}
+	var elems []jsonSpan
+	if msgs != nil {
+		if elems, closing, err = jsonElements(data, msgs.start); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	pending := newPendingEdits(edits)
+	var out bytes.Buffer
+	var tail []byte
+	switch {
+	case msgs != nil && len(elems) > 0:
+		out.Write(data[:msgs.start+1])
+		tail = data[elems[len(elems)-1].end:]
+	case msgs != nil:
+		out.Write(data[:msgs.start+1])
+		tail = data[closing:]
+	case len(fields) > 0:
+		// No messages yet: add them after the last field.
+		last := fields[len(fields)-1]
+		out.Write(data[:last.end])
+		out.WriteString("," + last.ws + `"messages": [`)
+		tail = append([]byte(last.ws+"]"), data[last.end:]...)
+	default:
+		out.Write(data[:closing])
+		out.WriteString(`"messages": [`)
+		tail = append([]byte("]"), data[closing:]...)
+	}
+
+	kept := 0
+	for _, el := range elems {
+		raw := data[el.start:el.end]
+		var m langmessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, nil, err
+		}
+		e, ok := pending.take(m.ID)
+		if ok && e.Delete {
+			continue
+		}
+		if ok {
+			if m.Translation.Cases != nil {
+				return nil, nil, fmt.Errorf("%w: %s has plural forms", ErrNotEditable, m.ID)
+			}
+			if raw, err = withTranslation(raw, e.Value); err != nil {
+				return nil, nil, err
+			}
+		}
+		if kept > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteString(el.ws)
+		out.Write(raw)
+		kept++
+	}
+
+	remaining := pending.rest()
+	if add {
+		remaining = []Edit{}
+		ws, unit := gotextIndent(data, fields, msgs, elems)
+		indent := ws[strings.LastIndexByte(ws, '\n')+1:]
+		for _, e := range pending.rest() {
+			if e.Delete {
+				remaining = append(remaining, e)
+				continue
+			}
+			added, err := marshalJSONIndent(langmessage{ID: e.Key, Message: e.Key, Translation: gotextTranslation{Msg: e.Value}}, indent, unit)
+			if err != nil {
+				return nil, nil, err
+			}
+			if kept > 0 {
+				out.WriteByte(',')
+			}
+			out.WriteString(ws)
+			out.Write(added)
+			kept++
+		}
+		if len(elems) == 0 && kept > 0 && msgs != nil {
+			// The closing bracket of an empty list goes on its own line.
+			out.WriteString(msgs.ws)
+		}
+	}
+
+	out.Write(tail)
+	return out.Bytes(), remaining, nil
+}
+
+// gotextIndent gets the whitespace before a new message, and the unit of indentation of the file.
+func gotextIndent(data []byte, fields []jsonSpan, msgs *jsonSpan, elems []jsonSpan) (string, string) {
+	lineIndent := func(ws string) string {
+		return ws[strings.LastIndexByte(ws, '\n')+1:]
+	}
+	if len(elems) > 0 {
+		ws := elems[len(elems)-1].ws
+		if msgFields, _, err := jsonFields(data, elems[0].start); err == nil && len(msgFields) > 0 {
+			outer, inner := lineIndent(elems[0].ws), lineIndent(msgFields[0].ws)
+			if len(inner) > len(outer) && strings.HasPrefix(inner, outer) {
+				return ws, inner[len(outer):]
+			}
+		}
+		return ws, "  "
+	}
+	unit := "  "
+	if len(fields) > 0 && lineIndent(fields[0].ws) != "" {
+		unit = lineIndent(fields[0].ws)
+	}
+	if msgs == nil && len(fields) > 0 {
+		return fields[0].ws + unit, unit
+	}
+	if len(fields) > 0 {
+		return "\n" + lineIndent(fields[0].ws) + unit, unit
+	}
+	return "\n" + unit, unit
+}
+
+// withTranslation replaces the translation of a message, leaving its other fields as they are.
+func withTranslation(msg []byte, value string) ([]byte, error) {
+	fields, _, err := jsonFields(msg, 0)
+	if err != nil {
+		return nil, err
+	}
+	translation, err := marshalJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	for _, f := range fields {
+		if f.key == "translation" {
+			out = append(out, msg[:f.start]...)
+			out = append(out, translation...)
+			return append(out, msg[f.end:]...), nil
+		}
+	}
+	if len(fields) == 0 {
+		return marshalJSON(langmessage{Translation: gotextTranslation{Msg: value}})
+	}
+	last := fields[len(fields)-1]
+	out = append(out, msg[:last.end]...)
+	out = append(out, ","+last.ws+`"translation": `...)
+	out = append(out, translation...)
+	return append(out, msg[last.end:]...), nil
+}
+
+// jsonSpan is where a value is in a JSON document.
+type jsonSpan struct {
+	// key is the name of an object field.
+	key        string
+	start, end int
+	// ws is the whitespace before the field or array element.
+	ws string
+}
+
+// jsonFields finds the fields of the JSON object at offset in data, and the offset of its closing brace.
+func jsonFields(data []byte, offset int) ([]jsonSpan, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data[offset:]))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, 0, errors.New("expected a JSON object")
+	}
+	fields := []jsonSpan{}
+	for dec.More() {
+		prev := offset + int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, 0, err
+		}
+		end := offset + int(dec.InputOffset())
+		keyStart := prev + len(data[prev:]) - len(bytes.TrimLeft(data[prev:], " \t\r\n,"))
+		fields = append(fields, jsonSpan{
+			key:   tok.(string),
+			start: end - len(raw),
+			end:   end,
+			ws:    jsonSpace(data, keyStart),
+		})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, 0, err
+	}
+	return fields, offset + int(dec.InputOffset()) - 1, nil
+}
+
+// jsonElements finds the elements of the JSON array at offset in data, and the offset of its closing bracket.
+func jsonElements(data []byte, offset int) ([]jsonSpan, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data[offset:]))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, 0, errors.New("expected a JSON array")
+	}
+	elems := []jsonSpan{}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, 0, err
+		}
+		end := offset + int(dec.InputOffset())
+		elems = append(elems, jsonSpan{start: end - len(raw), end: end, ws: jsonSpace(data, end-len(raw))})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, 0, err
+	}
+	return elems, offset + int(dec.InputOffset()) - 1, nil
+}
+
+// jsonSpace gets the whitespace just before offset in data.
+func jsonSpace(data []byte, offset int) string {
+	return string(data[len(bytes.TrimRight(data[:offset], " \t\r\n")):offset])
+}
+
+// marshalJSON is json.Marshal without escaping HTML, which translations are full of.
+func marshalJSON(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// marshalJSONIndent is marshalJSON with the indentation of json.MarshalIndent.
+func marshalJSONIndent(v interface{}, prefix, indent string) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(prefix, indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -1,11 +1,13 @@
 package loader
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
 )
 
 func TestSimpleGoTextJSONLoad(t *testing.T) {
@@ -92,3 +94,171 @@ func TestMultipleGoTextJSONLoad(t *testing.T) {
 	assert.Equal(t, "chinese foo", p.Sprintf("foo"))
 	assert.Equal(t, "chinese bar", p.Sprintf("bar"))
 }
+
+func TestGoTextJSONEditFile(t *testing.T) {
+	data := `{
+  "language": "fr-fr",
+  "messages": [
+    {"id": "greeting", "message": "hello", "translation": "bonjour", "placeholders": []},
+    {"id": "farewell", "message": "bye", "translation": "au revoir"}
+  ]
+}`
+
+	ldr := NewGoTextJSONLoader()
+	out, rest, err := ldr.EditFile("messages.json", []byte(data), language.French, []Edit{
+		{Key: "greeting", Value: "salut & <bienvenue>"},
+		{Key: "farewell", Delete: true},
+		{Key: "new", Value: "nouveau"},
+	}, true)
+	assert.Nil(t, err)
+	assert.Empty(t, rest)
+	assert.Equal(t, `{
+  "language": "fr-fr",
+  "messages": [
+    {"id": "greeting", "message": "hello", "translation": "salut & <bienvenue>", "placeholders": []},
+    {
+      "id": "new",
+      "message": "new",
+      "translation": "nouveau"
+    }
+  ]
+}`, string(out))
+
+	_, rest, err = ldr.EditFile("messages.json", out, language.French, []Edit{{Key: "missing", Delete: true}}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []Edit{{Key: "missing", Delete: true}}, rest)
+}
+
+func TestGoTextJSONEditFileKeepsBytes(t *testing.T) {
+	// Laid out the way gotext writes it, with fields in gotext's order and ones this loader ignores.
+	data := `{
+    "language": "de-DE",
+    "messages": [
+        {
+            "id": "Hello {Name}",
+            "message": "Hello {Name}",
+            "translation": "Hallo {Name}",
+            "placeholders": [
+                {
+                    "id": "Name",
+                    "string": "%[1]s",
+                    "type": "string",
+                    "underlyingType": "string",
+                    "argNum": 1,
+                    "expr": "name"
+                }
+            ]
+        },
+        {
+            "id": "Bye",
+            "message": "Bye",
+            "translation": "Tschüss",
+            "fuzzy": true
+        },
+        {
+            "id": "{Count} files",
+            "message": "{Count} files",
+            "translation": {"select": {"feature": "plural", "arg": "Count", "cases": {"one": "{Count} Datei", "other": "{Count} Dateien"}}}
+        }
+    ]
+}
+`
+
+	ldr := NewGoTextJSONLoader()
+	out, rest, err := ldr.EditFile("messages.gotext.json", []byte(data), language.German, []Edit{
+		{Key: "Bye", Value: "Auf Wiedersehen"},
+		{Key: "New", Value: "Neu"},
+	}, true)
+	assert.Nil(t, err)
+	assert.Empty(t, rest)
+
+	// Everything but the edited translation and the new message is unchanged.
+	edited := strings.Replace(data, `"Tschüss"`, `"Auf Wiedersehen"`, 1)
+	added := `,
+        {
+            "id": "New",
+            "message": "New",
+            "translation": "Neu"
+        }`
+	end := strings.LastIndex(edited, "}\n    ]") + 1
+	assert.Equal(t, edited[:end]+added+edited[end:], string(out))
+
+	// Plural forms can't be edited, only deleted.
+	_, _, err = ldr.EditFile("messages.gotext.json", []byte(data), language.German, []Edit{
+		{Key: "{Count} files", Value: "Dateien"},
+	}, false)
+	assert.True(t, errors.Is(err, ErrNotEditable))
+
+	out, rest, err = ldr.EditFile("messages.gotext.json", []byte(data), language.German, []Edit{
+		{Key: "{Count} files", Delete: true},
+	}, false)
+	assert.Nil(t, err)
+	assert.Empty(t, rest)
+	end = strings.Index(data, ",\n        {\n            \"id\": \"{Count} files\"")
+	assert.Equal(t, data[:end]+"\n    ]\n}\n", string(out))
+}
+
+func TestGoTextJSONEditFileEmpty(t *testing.T) {
+	ldr := NewGoTextJSONLoader()
+	out, _, err := ldr.EditFile("messages.json", []byte("{\n  \"language\": \"fr-fr\",\n  \"messages\": []\n}\n"),
+		language.French, []Edit{{Key: "new", Value: "nouveau"}}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+  "language": "fr-fr",
+  "messages": [
+    {
+      "id": "new",
+      "message": "new",
+      "translation": "nouveau"
+    }
+  ]
+}
+`, string(out))
+
+	out, _, err = ldr.EditFile("messages.json", []byte("{\n  \"language\": \"fr-fr\"\n}"),
+		language.French, []Edit{{Key: "new", Value: "nouveau"}}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+  "language": "fr-fr",
+  "messages": [
+    {
+      "id": "new",
+      "message": "new",
+      "translation": "nouveau"
+    }
+  ]
+}`, string(out))
+}
+
+func TestGoTextJSONLoadPlurals(t *testing.T) {
+	data := `{
+  "language": "ru-ru",
+  "messages": [
+    {
+      "id": "files",
+      "message": "{Count} files",
+      "comment": "File count.",
+      "translation": {"select": {"feature": "plural", "arg": "Count", "cases": {
+        "one": {"msg": "{Count} файл"},
+        "few": "{Count} файла",
+        "other": {"msg": "{Count} файлов"}
+      }}}
+    }
+  ]
+}`
+
+	ldr := NewGoTextJSONLoader()
+	Isolate(ldr)
+	err := ldr.ReadMessages(strings.NewReader(data), nil, time.Now())
+	assert.Nil(t, err)
+
+	cat, _ := ldr.StringsByTag(language.MustParse("ru-ru"))
+	assert.Equal(t, "{Count} файлов", cat.Strings["files"])
+	assert.Equal(t, map[string]string{
+		MetadataComment:                "File count.",
+		MetadataPluralArg:              "Count",
+		MetadataPluralPrefix + "one":   "{Count} файл",
+		MetadataPluralPrefix + "few":   "{Count} файла",
+		MetadataPluralPrefix + "other": "{Count} файлов",
+	}, cat.Metadata["files"])
+}
@@ -0,0 +1,159 @@
+package loader
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultMaxBackoff caps how long an HTTPSource waits between polls after repeated failures.
+const DefaultMaxBackoff = 10 * time.Minute
+
+// IsURL reports whether localesDir names an HTTP(S) bundle rather than a local path.
+func IsURL(localesDir string) bool {
+	lower := strings.ToLower(localesDir)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// HTTPSource reads a locales tree from an archive (zip, tar, or tar.gz) served over HTTP(S).
+// It polls the URL with conditional requests and keeps serving the last good snapshot
+// when the origin fails, backing off exponentially until it recovers.
+type HTTPSource struct {
+	URL        string
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	Client     *http.Client
+
+	mu           sync.Mutex
+	snapshot     fs.FS
+	etag         string
+	lastModified string
+
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// NewHTTPSource is a factory method for HTTPSource. It fetches the bundle once, failing if that
+// doesn't succeed, and then polls every interval for changes. An interval of 0 disables polling.
+func NewHTTPSource(url string, interval time.Duration) (*HTTPSource, error) {
+	src := &HTTPSource{
+		URL:        url,
+		Interval:   interval,
+		MaxBackoff: DefaultMaxBackoff,
+		Client:     &http.Client{Timeout: time.Minute},
+		done:       make(chan struct{}),
+	}
+
+	if _, err := src.fetch(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		src.changed = make(chan struct{}, 1)
+		go src.poll()
+	}
+	return src, nil
+}
+
+// Open implements the Source interface. It returns the last good snapshot.
+func (src *HTTPSource) Open() (fs.FS, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.snapshot, nil
+}
+
+// Changed implements the Source interface.
+func (src *HTTPSource) Changed() <-chan struct{} {
+	if src.changed == nil {
+		return nil
+	}
+	return src.changed
+}
+
+// Close implements the Source interface.
+func (src *HTTPSource) Close() error {
+	close(src.done)
+	return nil
+}
+
+func (src *HTTPSource) poll() {
+	delay := src.Interval
+	for {
+		select {
+		case <-src.done:
+			return
+		case <-time.After(delay):
+		}
+
+		updated, err := src.fetch()
+		if err != nil {
+			delay *= 2
+			if delay > src.MaxBackoff {
+				delay = src.MaxBackoff
+			}
+			log.Warn().Str("url", src.URL).Err(err).Dur("retry_in", delay).Msg("Error polling for locales, keeping last snapshot")
+			continue
+		}
+
+		delay = src.Interval
+		if updated {
+			notify(src.changed)
+		}
+	}
+}
+
+// fetch gets the bundle if it has changed since the last fetch, and reports whether it had.
+func (src *HTTPSource) fetch() (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, src.URL, nil)
+	if err != nil {
+		return false, err
+	}
+
+	src.mu.Lock()
+	if src.etag != "" {
+		req.Header.Set("If-None-Match", src.etag)
+	}
+	if src.lastModified != "" {
+		req.Header.Set("If-Modified-Since", src.lastModified)
+	}
+	src.mu.Unlock()
+
+	res, err := src.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		log.Debug().Str("url", src.URL).Msg("Locales not modified")
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("%s: unexpected status %s", src.URL, res.Status)
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return false, err
+	}
+	fsys, err := archiveToFS(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", src.URL, err)
+	}
+
+	log.Info().Str("url", src.URL).Str("etag", res.Header.Get("ETag")).Msg("Fetched locales")
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.snapshot = fsys
+	src.etag = res.Header.Get("ETag")
+	src.lastModified = res.Header.Get("Last-Modified")
+	return true, nil
+}
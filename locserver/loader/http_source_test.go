@@ -0,0 +1,117 @@
+package loader
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+// bundleOrigin is a stand-in for a central server publishing locale bundles.
+type bundleOrigin struct {
+	mu          sync.Mutex
+	bundle      []byte
+	version     int
+	failing     bool
+	notModified int
+}
+
+func (o *bundleOrigin) publish(bundle []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.bundle = bundle
+	o.version++
+}
+
+func (o *bundleOrigin) setFailing(failing bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.failing = failing
+}
+
+func (o *bundleOrigin) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.failing {
+		res.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	etag := fmt.Sprintf(`"v%d"`, o.version)
+	if req.Header.Get("If-None-Match") == etag {
+		o.notModified++
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+	res.Header().Set("ETag", etag)
+	res.Write(o.bundle)
+}
+
+func TestIsURL(t *testing.T) {
+	assert.True(t, IsURL("https://cdn.example.com/translations.tar.gz"))
+	assert.True(t, IsURL("HTTP://localhost:8080/locales.zip"))
+	assert.False(t, IsURL("./locales-po"))
+}
+
+func TestHTTPSourcePolling(t *testing.T) {
+	origin := &bundleOrigin{}
+	origin.publish(zipArchive(t, map[string]string{
+		"sv-se/messages.po": string(poFile("greeting", "hej").Data),
+	}))
+	server := httptest.NewServer(origin)
+	defer server.Close()
+
+	src, err := NewHTTPSource(server.URL+"/translations.zip", 20*time.Millisecond)
+	assert.Nil(t, err)
+	src.MaxBackoff = 40 * time.Millisecond
+
+	st, err := NewStringTableSource(src, NewPOLoader())
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+
+	cat, err := st.StringsByTag(language.MustParse("sv-se"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hej", cat.Strings["greeting"])
+
+	// Unchanged bundles are not downloaded again.
+	assert.Eventually(t, func() bool {
+		origin.mu.Lock()
+		defer origin.mu.Unlock()
+		return origin.notModified >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// While the origin is down, the last good snapshot keeps being served.
+	origin.setFailing(true)
+	time.Sleep(100 * time.Millisecond)
+	cat, err = st.StringsByTag(language.MustParse("sv-se"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hej", cat.Strings["greeting"])
+
+	// Once it recovers, new bundles are picked up.
+	origin.publish(tarGzArchive(t, map[string]string{
+		"sv-se/messages.po": string(poFile("greeting", "god dag").Data),
+	}))
+	origin.setFailing(false)
+	assert.Eventually(t, func() bool {
+		cat, err := st.StringsByTag(language.MustParse("sv-se"))
+		return err == nil && cat.Strings["greeting"] == "god dag"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestHTTPSourceInitialFailure(t *testing.T) {
+	origin := &bundleOrigin{failing: true}
+	server := httptest.NewServer(origin)
+	defer server.Close()
+
+	_, err := NewHTTPSource(server.URL, time.Minute)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "503")
+}
@@ -1,18 +1,42 @@
 package loader
 
 import (
+	"errors"
 	"io"
 	"time"
 
 	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
 )
 
 // StringCatalog lets us store an entire catalog by tag.
 type StringCatalog struct {
 	Strings     map[string]string
 	LastModTime time.Time
+
+	// Metadata holds optional per-key attributes, such as comments or context, for loaders that provide them.
+	Metadata map[string]map[string]string
 }
 
+// Well-known Metadata keys, used by the loaders and writers of formats that can represent them.
+const (
+	// MetadataComment is a note to translators, such as a PO extracted comment.
+	MetadataComment = "comment"
+	// MetadataTranslatorComment is a note from translators.
+	MetadataTranslatorComment = "translator_comment"
+	// MetadataContext tells apart messages with the same text, such as a PO msgctxt.
+	MetadataContext = "context"
+	// MetadataPluralPrefix starts the keys of plural forms, followed by their CLDR category
+	// (e.g. "plural.one"), or by their index for PO files whose categories aren't known.
+	// The string itself is the "other" form.
+	MetadataPluralPrefix = "plural."
+	// MetadataPluralID is the plural of the message text, such as a PO msgid_plural.
+	MetadataPluralID = "plural_id"
+	// MetadataPluralArg is the placeholder that selects the plural form, such as a gotext select arg.
+	MetadataPluralArg = "plural_arg"
+)
+
 // Loader loads messages.
 type Loader interface {
 	StringsByTag(tag language.Tag) (*StringCatalog, error)
@@ -26,10 +50,117 @@ type Loader interface {
 	ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error
 }
 
+// FileLoader is implemented by loaders that need to know which file they are reading,
+// for example to pick a format by file extension.
+type FileLoader interface {
+	Loader
+
+	// ReadFile loads messages from the given reader, which holds the contents of the file at path.
+	ReadFile(path string, reader io.Reader, tag *language.Tag, modTime time.Time) error
+}
+
+// Edit is a change to the translation of one key.
+type Edit struct {
+	Key   string
+	Value string
+	// Delete removes the key instead of setting Value.
+	Delete bool
+}
+
+// ErrNotEditable is returned by a FileEditor for a file it can't write to.
+var ErrNotEditable = errors.New("file can't be edited")
+
+// FileEditor is implemented by loaders that can write changes back into the files they read,
+// leaving the rest of the file, such as comments and other messages, as it was.
+type FileEditor interface {
+	// EditFile applies edits to the keys already in data, which holds the contents of the file at path.
+	// If add is true, other edits that aren't deletes are added to the file.
+	// It returns the new contents and the edits that were not applied.
+	EditFile(path string, data []byte, tag language.Tag, edits []Edit, add bool) ([]byte, []Edit, error)
+}
+
 // NewStringCatalog factory method.
 func NewStringCatalog(modTime time.Time) *StringCatalog {
 	return &StringCatalog{
 		Strings:     map[string]string{},
 		LastModTime: modTime,
+		Metadata:    map[string]map[string]string{},
+	}
+}
+
+// messageSetter is embedded by loaders to set the strings they read for message.Printer.
+type messageSetter struct {
+	// private, if set, gets the strings instead of the default message catalog.
+	private *catalog.Builder
+}
+
+func (s *messageSetter) setString(tag language.Tag, key, msg string) {
+	if s.private != nil {
+		s.private.SetString(tag, key, msg)
+		return
+	}
+	message.SetString(tag, key, msg)
+}
+
+func (s *messageSetter) isolate() bool {
+	s.private = catalog.NewBuilder()
+	return true
+}
+
+// languages gets the languages read by an isolated loader.
+func (s *messageSetter) languages() []language.Tag {
+	if s.private == nil {
+		return nil
+	}
+	return s.private.Languages()
+}
+
+type isolator interface {
+	isolate() bool
+	languages() []language.Tag
+}
+
+// Isolate makes a new loader read files without setting their strings for message.Printer,
+// so that they can be inspected without being served. It reports whether the loader supports this.
+// Members of a CompositeLoader that don't support it are removed.
+func Isolate(ldr Loader) bool {
+	if i, ok := ldr.(isolator); ok {
+		return i.isolate()
+	}
+	return false
+}
+
+// pendingEdits tracks which of a set of edits a FileEditor has applied. Later edits to a key win.
+type pendingEdits struct {
+	byKey map[string]Edit
+	order []string
+}
+
+func newPendingEdits(edits []Edit) *pendingEdits {
+	p := &pendingEdits{byKey: map[string]Edit{}}
+	for _, e := range edits {
+		if _, ok := p.byKey[e.Key]; !ok {
+			p.order = append(p.order, e.Key)
+		}
+		p.byKey[e.Key] = e
+	}
+	return p
+}
+
+// take gets and removes the edit for key, if there is one.
+func (p *pendingEdits) take(key string) (Edit, bool) {
+	e, ok := p.byKey[key]
+	delete(p.byKey, key)
+	return e, ok
+}
+
+// rest gets the edits that have not been taken, in their original order.
+func (p *pendingEdits) rest() []Edit {
+	edits := []Edit{}
+	for _, key := range p.order {
+		if e, ok := p.byKey[key]; ok {
+			edits = append(edits, e)
+		}
 	}
+	return edits
 }
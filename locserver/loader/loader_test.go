@@ -1,6 +1,11 @@
 package loader
 
 import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 )
@@ -9,3 +14,19 @@ func getPrinter(lang string) *message.Printer {
 	tag, _ := language.Parse(lang)
 	return message.NewPrinter(tag)
 }
+
+func TestIsolate(t *testing.T) {
+	tag := language.MustParse("fi-fi")
+	ldr := NewPOLoader()
+	assert.True(t, Isolate(ldr))
+
+	err := ldr.ReadMessages(strings.NewReader(string(poFile("greeting", "moi").Data)), &tag, time.Now())
+	assert.Nil(t, err)
+
+	cat, err := ldr.StringsByTag(tag)
+	assert.Nil(t, err)
+	assert.Equal(t, "moi", cat.Strings["greeting"])
+	assert.Equal(t, "greeting", getPrinter("fi-fi").Sprintf("greeting"))
+
+	assert.False(t, Isolate(&upperLoader{}))
+}
@@ -0,0 +1,132 @@
+package loader
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+)
+
+// moMagic is the first word of a gettext MO file, in the byte order the rest of the file uses.
+const moMagic = 0x950412de
+
+// MOLoader loads strings from files in the gettext MO format, the compiled form of PO files.
+type MOLoader struct {
+	catalogsByTagStr map[string]*StringCatalog
+	messageSetter
+}
+
+// NewMOLoader factory method.
+func NewMOLoader() *MOLoader {
+	return &MOLoader{
+		catalogsByTagStr: map[string]*StringCatalog{},
+	}
+}
+
+// StringsByTag gets the string table for the given language tag.
+func (ldr *MOLoader) StringsByTag(tag language.Tag) (*StringCatalog, error) {
+	if cat, ok := ldr.catalogsByTagStr[tag.String()]; ok {
+		return cat, nil
+	}
+	return nil, errors.New("catalog not found for tag " + tag.String())
+}
+
+// NeedsTag implements the Loader interface.
+func (ldr *MOLoader) NeedsTag() bool {
+	// Needed because the language is only in the header, if at all.
+	return true
+}
+
+// ReadMessages implements the Loader interface.
+// Plural entries keep their forms in the metadata, as the PO loader does.
+func (ldr *MOLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	if tag == nil {
+		return errors.New("tag string is required by MO loader")
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if len(data) < 20 {
+		return errors.New("MO file is too short")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(data) != moMagic {
+		order = binary.BigEndian
+		if order.Uint32(data) != moMagic {
+			return errors.New("not an MO file")
+		}
+	}
+	n := order.Uint32(data[8:])
+	origTable, transTable := order.Uint32(data[12:]), order.Uint32(data[16:])
+
+	// str gets the i-th string of a table of lengths and offsets.
+	str := func(table, i uint32) (string, error) {
+		entry := uint64(table) + uint64(i)*8
+		if entry+8 > uint64(len(data)) {
+			return "", errors.New("MO string table is out of range")
+		}
+		length, offset := uint64(order.Uint32(data[entry:])), uint64(order.Uint32(data[entry+4:]))
+		if offset+length > uint64(len(data)) {
+			return "", errors.New("MO string is out of range")
+		}
+		return string(data[offset : offset+length]), nil
+	}
+
+	tagStr := tag.String()
+	cat := NewStringCatalog(modTime)
+	for i := uint32(0); i < n; i++ {
+		orig, err := str(origTable, i)
+		if err != nil {
+			return err
+		}
+		trans, err := str(transTable, i)
+		if err != nil {
+			return err
+		}
+		if orig == "" {
+			// The header.
+			continue
+		}
+
+		meta := map[string]string{}
+		if sep := strings.IndexByte(orig, '\x04'); sep >= 0 {
+			meta[MetadataContext] = orig[:sep]
+			orig = orig[sep+1:]
+		}
+		id, translation := orig, trans
+		if sep := strings.IndexByte(orig, '\x00'); sep >= 0 {
+			id = orig[:sep]
+			meta[MetadataPluralID] = orig[sep+1:]
+			forms := strings.Split(trans, "\x00")
+			categories := poPluralCategories(len(forms))
+			for j, form := range forms {
+				meta[MetadataPluralPrefix+categories[j]] = form
+			}
+			translation = forms[len(forms)-1]
+		}
+
+		if translation == "" {
+			continue
+		}
+		log.Debug().Str("languagetag", tagStr).
+			Str("id", id).
+			Str("translation", translation).
+			Msg("Loading string")
+		ldr.setString(*tag, id, translation)
+
+		cat.Strings[id] = translation
+		if len(meta) > 0 {
+			cat.Metadata[id] = meta
+		}
+	}
+	ldr.catalogsByTagStr[tagStr] = cat
+
+	return nil
+}
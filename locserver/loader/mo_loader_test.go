@@ -0,0 +1,73 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+// moFile builds an MO file from pairs of original and translated strings.
+func moFile(order binary.ByteOrder, pairs ...string) []byte {
+	n := uint32(len(pairs) / 2)
+	origTable := uint32(28)
+	transTable := origTable + n*8
+	offset := transTable + n*8
+
+	var header, tables, strs bytes.Buffer
+	for _, v := range []uint32{moMagic, 0, n, origTable, transTable, 0, 0} {
+		binary.Write(&header, order, v)
+	}
+	for table := 0; table < 2; table++ {
+		for i := table; i < len(pairs); i += 2 {
+			binary.Write(&tables, order, uint32(len(pairs[i])))
+			binary.Write(&tables, order, offset+uint32(strs.Len()))
+			strs.WriteString(pairs[i] + "\x00")
+		}
+	}
+	return append(append(header.Bytes(), tables.Bytes()...), strs.Bytes()...)
+}
+
+func TestMOLoader(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data := moFile(order,
+			"", "Content-Type: text/plain; charset=UTF-8\n",
+			"colour", "couleur",
+			"menu\x04open", "ouvrir",
+			"%d file\x00%d files", "%d fichier\x00%d fichiers",
+			"untranslated", "",
+		)
+
+		ldr := NewMOLoader()
+		tag := language.MustParse("fr-fr")
+		assert.Nil(t, ldr.ReadMessages(bytes.NewReader(data), &tag, time.Now()))
+
+		cat, err := ldr.StringsByTag(tag)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{
+			"colour":  "couleur",
+			"open":    "ouvrir",
+			"%d file": "%d fichiers",
+		}, cat.Strings)
+		assert.Equal(t, map[string]string{MetadataContext: "menu"}, cat.Metadata["open"])
+		assert.Equal(t, map[string]string{
+			MetadataPluralID:               "%d files",
+			MetadataPluralPrefix + "one":   "%d fichier",
+			MetadataPluralPrefix + "other": "%d fichiers",
+		}, cat.Metadata["%d file"])
+	}
+}
+
+func TestMOLoaderErrors(t *testing.T) {
+	tag := language.MustParse("fr-fr")
+	ldr := NewMOLoader()
+	assert.NotNil(t, ldr.ReadMessages(bytes.NewReader([]byte("\xde\x12\x04\x95")), &tag, time.Now()))
+	assert.NotNil(t, ldr.ReadMessages(bytes.NewReader(make([]byte, 28)), &tag, time.Now()))
+	assert.NotNil(t, ldr.ReadMessages(bytes.NewReader(moFile(binary.LittleEndian, "a", "b")), nil, time.Now()))
+
+	truncated := moFile(binary.LittleEndian, "colour", "couleur")
+	assert.NotNil(t, ldr.ReadMessages(bytes.NewReader(truncated[:len(truncated)-4]), &tag, time.Now()))
+}
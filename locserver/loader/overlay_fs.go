@@ -0,0 +1,67 @@
+package loader
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// overlayFS layers one file system over another. Files in upper hide files with the same
+// path in lower, and directory listings are merged.
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+// NewOverlayFS creates a file system where files in upper take precedence over files in lower.
+// upper may be missing entirely, such as an os.DirFS of a directory that does not exist.
+func NewOverlayFS(upper, lower fs.FS) fs.FS {
+	return &overlayFS{
+		upper: upper,
+		lower: lower,
+	}
+}
+
+// Open implements fs.FS.
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.lower.Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upperEntries, upperErr := fs.ReadDir(o.upper, name)
+	if upperErr != nil && !errors.Is(upperErr, fs.ErrNotExist) {
+		return nil, upperErr
+	}
+	lowerEntries, lowerErr := fs.ReadDir(o.lower, name)
+	if lowerErr != nil && !errors.Is(lowerErr, fs.ErrNotExist) {
+		return nil, lowerErr
+	}
+	if upperErr != nil && lowerErr != nil {
+		return nil, upperErr
+	}
+
+	byName := map[string]fs.DirEntry{}
+	for _, e := range lowerEntries {
+		byName[e.Name()] = e
+	}
+	for _, e := range upperEntries {
+		byName[e.Name()] = e
+	}
+
+	entries := make([]fs.DirEntry, 0, len(byName))
+	for _, e := range byName {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
@@ -0,0 +1,273 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/language"
+)
+
+// cldrCategories are the plural categories platforms with native plurals understand.
+var cldrCategories = map[string]bool{"zero": true, "one": true, "two": true, "few": true, "many": true, "other": true}
+
+// cldrPluralForms gets the plural forms of a string if they all have CLDR categories.
+func cldrPluralForms(meta map[string]string) ([]string, []string, bool) {
+	categories, forms := pluralForms(meta)
+	for _, c := range categories {
+		if !cldrCategories[c] {
+			return nil, nil, false
+		}
+	}
+	return categories, forms, len(forms) > 0
+}
+
+var (
+	androidNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_.]`)
+	androidEscaper     = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// WriteAndroidXML writes a catalog as an Android strings.xml resource file.
+// Keys are changed into valid resource names, and plural forms become plurals resources.
+func WriteAndroidXML(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	var buf bytes.Buffer
+	d := dropped{}
+	renamed, clashes := 0, 0
+	names := map[string]bool{}
+
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<resources>\n")
+	for _, key := range sortedKeys(cat.Strings) {
+		name := androidNameInvalid.ReplaceAllString(key, "_")
+		if name == "" || name[0] >= '0' && name[0] <= '9' {
+			name = "_" + name
+		}
+		if name != key {
+			renamed++
+		}
+		if names[name] {
+			clashes++
+			continue
+		}
+		names[name] = true
+
+		meta := cat.Metadata[key]
+		if c, ok := meta[MetadataComment]; ok {
+			buf.WriteString("    <!-- " + strings.Replace(c, "--", "- -", -1) + " -->\n")
+		}
+
+		categories, forms, ok := cldrPluralForms(meta)
+		if !ok {
+			d.keep(meta, MetadataComment)
+			buf.WriteString(`    <string name="` + name + `">` + androidEscape(cat.Strings[key]) + "</string>\n")
+			continue
+		}
+		d.keep(meta, MetadataComment, MetadataPluralPrefix, MetadataPluralID, MetadataPluralArg)
+		buf.WriteString(`    <plurals name="` + name + "\">\n")
+		for i, c := range categories {
+			buf.WriteString(`        <item quantity="` + c + `">` + androidEscape(forms[i]) + "</item>\n")
+		}
+		buf.WriteString("    </plurals>\n")
+	}
+	buf.WriteString("</resources>\n")
+
+	warnings := d.warnings("android")
+	if renamed > 0 {
+		warnings = append(warnings, fmt.Sprintf("android renamed %d keys that aren't valid resource names", renamed))
+	}
+	if clashes > 0 {
+		warnings = append(warnings, fmt.Sprintf("android left out %d keys with the same resource name as another", clashes))
+	}
+	_, err := w.Write(buf.Bytes())
+	return warnings, err
+}
+
+func androidEscape(s string) string {
+	s = androidEscaper.Replace(s)
+	if strings.HasPrefix(s, "@") || strings.HasPrefix(s, "?") {
+		s = `\` + s
+	}
+	return s
+}
+
+var appleStringsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+
+// WriteAppleStrings writes a catalog as an Apple .strings file.
+// Plural forms need a .stringsdict file, so only the "other" form is written.
+func WriteAppleStrings(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	var buf bytes.Buffer
+	d := dropped{}
+	for i, key := range sortedKeys(cat.Strings) {
+		meta := cat.Metadata[key]
+		d.keep(meta, MetadataComment)
+
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if c, ok := meta[MetadataComment]; ok {
+			buf.WriteString("/* " + strings.Replace(c, "*/", "* /", -1) + " */\n")
+		}
+		buf.WriteString(`"` + appleStringsEscaper.Replace(key) + `" = "` + appleStringsEscaper.Replace(cat.Strings[key]) + "\";\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return d.warnings("strings"), err
+}
+
+// WriteARB writes a catalog as an Application Resource Bundle, as used by Flutter.
+// Plural forms are written in ICU message syntax, on the plural_arg metadata or "count".
+func WriteARB(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	var buf bytes.Buffer
+	d := dropped{}
+
+	locale, err := marshalJSON(strings.Replace(tag.String(), "-", "_", -1))
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString("{\n  \"" + arbLocaleKey + "\": " + string(locale))
+
+	for _, key := range sortedKeys(cat.Strings) {
+		meta := cat.Metadata[key]
+		value := cat.Strings[key]
+		attrs := map[string]interface{}{}
+
+		if categories, forms, ok := cldrPluralForms(meta); ok {
+			d.keep(meta, MetadataComment, MetadataContext, MetadataPluralPrefix, MetadataPluralID, MetadataPluralArg)
+			arg := meta[MetadataPluralArg]
+			if arg == "" {
+				arg = "count"
+			}
+			value = "{" + arg + ", plural,"
+			for i, c := range categories {
+				value += " " + c + "{" + forms[i] + "}"
+			}
+			value += "}"
+			attrs["placeholders"] = map[string]interface{}{arg: map[string]string{"type": "num"}}
+		} else {
+			d.keep(meta, MetadataComment, MetadataContext)
+		}
+		if c, ok := meta[MetadataComment]; ok {
+			attrs["description"] = c
+		}
+		if c, ok := meta[MetadataContext]; ok {
+			attrs["context"] = c
+		}
+
+		k, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := marshalJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(",\n  " + string(k) + ": " + string(v))
+		if len(attrs) > 0 {
+			a, err := marshalJSON(attrs)
+			if err != nil {
+				return nil, err
+			}
+			ak, _ := marshalJSON("@" + key)
+			buf.WriteString(",\n  " + string(ak) + ": " + string(a))
+		}
+	}
+	buf.WriteString("\n}\n")
+
+	_, err = w.Write(buf.Bytes())
+	return d.warnings("arb"), err
+}
+
+// WriteYAML writes a catalog as a flat YAML map of keys to strings, with comments above them.
+// Plural forms are written as a map of categories to strings.
+func WriteYAML(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	var buf bytes.Buffer
+	d := dropped{}
+	for _, key := range sortedKeys(cat.Strings) {
+		meta := cat.Metadata[key]
+		if c, ok := meta[MetadataComment]; ok {
+			for _, line := range strings.Split(c, "\n") {
+				buf.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+			}
+		}
+
+		k, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+		categories, forms, ok := cldrPluralForms(meta)
+		if !ok {
+			d.keep(meta, MetadataComment)
+			v, err := marshalJSON(cat.Strings[key])
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(string(k) + ": " + string(v) + "\n")
+			continue
+		}
+
+		d.keep(meta, MetadataComment, MetadataPluralPrefix, MetadataPluralID, MetadataPluralArg)
+		buf.WriteString(string(k) + ":\n")
+		for i, c := range categories {
+			v, err := marshalJSON(forms[i])
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString("  " + c + ": " + string(v) + "\n")
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return d.warnings("yaml"), err
+}
+
+// WriteJavaProperties writes a catalog as a Java properties file, with characters outside ASCII escaped.
+// Plural forms can't be represented, so only the "other" form is written.
+func WriteJavaProperties(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	var buf bytes.Buffer
+	d := dropped{}
+	for _, key := range sortedKeys(cat.Strings) {
+		meta := cat.Metadata[key]
+		d.keep(meta, MetadataComment)
+		if c, ok := meta[MetadataComment]; ok {
+			for _, line := range strings.Split(c, "\n") {
+				buf.WriteString(strings.TrimRight("# "+propertiesEscape(line, false), " ") + "\n")
+			}
+		}
+		buf.WriteString(propertiesEscape(key, true) + "=" + propertiesEscape(cat.Strings[key], false) + "\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return d.warnings("properties"), err
+}
+
+func propertiesEscape(s string, key bool) string {
+	var buf strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			buf.WriteString(`\ `)
+		case key && strings.ContainsRune("=:#!", r):
+			buf.WriteString(`\`)
+			buf.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&buf, `\u%04X`, u)
+			}
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
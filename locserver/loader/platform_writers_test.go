@@ -0,0 +1,96 @@
+package loader
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func writeString(t *testing.T, format string, cat *StringCatalog) (string, []string) {
+	f, ok := LookupWriter(format)
+	if !ok {
+		t.Fatal("no writer for " + format)
+	}
+	var buf bytes.Buffer
+	warnings, err := f.Write(&buf, language.MustParse("de-de"), language.AmericanEnglish, cat)
+	assert.Nil(t, err)
+	return buf.String(), warnings
+}
+
+func TestWriteAndroidXML(t *testing.T) {
+	cat := testCatalog()
+	cat.Strings["home.title"] = "@Start"
+	cat.Strings["home-title"] = "It's home"
+
+	out, warnings := writeString(t, "android", cat)
+	assert.Equal(t, `<?xml version="1.0" encoding="utf-8"?>
+<resources>
+    <plurals name="files">
+        <item quantity="one">%d Datei</item>
+        <item quantity="other">%d Dateien</item>
+    </plurals>
+    <!-- Shown on the home page.
+Keep it short. -->
+    <string name="greeting">Say \"hi\" &amp; &lt;wave&gt;</string>
+    <string name="home_title">It\'s home</string>
+    <string name="home.title">\@Start</string>
+    <string name="plain">einfach</string>
+</resources>
+`, out)
+	assert.Equal(t, []string{
+		`android can't represent "context" metadata, left out of 1 strings`,
+		`android can't represent "translator_comment" metadata, left out of 1 strings`,
+		"android renamed 1 keys that aren't valid resource names",
+	}, warnings)
+}
+
+func TestWriteAppleStrings(t *testing.T) {
+	out, warnings := writeString(t, "strings", testCatalog())
+	assert.Equal(t, `"files" = "%d Dateien";
+
+/* Shown on the home page.
+Keep it short. */
+"greeting" = "Say \"hi\" & <wave>";
+
+"plain" = "einfach";
+`, out)
+	assert.Contains(t, warnings, "strings can't represent plural forms, left out of 1 strings")
+}
+
+func TestWriteARB(t *testing.T) {
+	cat := testCatalog()
+	out, _ := writeString(t, "arb", cat)
+	assert.Contains(t, out, `"files": "{count, plural, one{%d Datei} other{%d Dateien}}"`)
+	assert.Contains(t, out, `"@greeting": {"context":"home","description":"Shown on the home page.\nKeep it short."}`)
+
+	read, warnings := roundTrip(t, "arb", "de.arb", cat)
+	assert.Equal(t, []string{`arb can't represent "translator_comment" metadata, left out of 1 strings`}, warnings)
+	assert.Equal(t, cat.Strings["greeting"], read.Strings["greeting"])
+	assert.Equal(t, "Shown on the home page.\nKeep it short.", read.Metadata["greeting"][MetadataComment])
+}
+
+func TestWriteYAML(t *testing.T) {
+	out, _ := writeString(t, "yaml", testCatalog())
+	assert.Equal(t, `"files":
+  one: "%d Datei"
+  other: "%d Dateien"
+# Shown on the home page.
+# Keep it short.
+"greeting": "Say \"hi\" & <wave>"
+"plain": "einfach"
+`, out)
+}
+
+func TestWriteJavaProperties(t *testing.T) {
+	cat := NewStringCatalog(testCatalog().LastModTime)
+	cat.Strings["greeting key"] = " Grüße:\n=bis bald"
+	cat.Strings["emoji"] = "😀"
+
+	out, warnings := writeString(t, "properties", cat)
+	assert.Equal(t, `emoji=\uD83D\uDE00
+greeting\ key=\ Gr\u00FC\u00DFe:\n=bis bald
+`, out)
+	assert.Empty(t, warnings)
+}
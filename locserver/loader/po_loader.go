@@ -1,22 +1,39 @@
 package loader
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
-const pattern = `msgid "(.+)"\nmsgstr "(.+)"`
+// pattern finds translated entries, singular or plural, with the comments and context above them.
+var pattern = regexp.MustCompile(`(?m)((?:^#.*\n)*)(?:^msgctxt "(.*)"\n)?^msgid "(.+)"\n` +
+	`(?:^msgid_plural "(.*)"\n((?:^msgstr\[\d+\] ".*"(?:\n|$))+)|^msgstr "(.+)")`)
+
+// pluralFormPattern finds the forms of a plural entry, in order.
+var pluralFormPattern = regexp.MustCompile(`(?m)^msgstr\[\d+\] "(.*)"`)
+
+// entryPattern finds singular entries for editing, along with the comments and context above them.
+var entryPattern = regexp.MustCompile(`(?m)((?:^#.*\n)*)(?:^msgctxt "(.*)"\n)?^msgid "(.+)"\nmsgstr "(.*)"$`)
+
+// pluralIDPattern finds the ids of plural entries, which can't be edited.
+var pluralIDPattern = regexp.MustCompile(`(?m)^msgid "(.+)"\nmsgid_plural `)
+
+var poEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
 
 // POLoader loads strings from files in the gettext PO format.
 type POLoader struct {
 	catalogsByTagStr map[string]*StringCatalog
+	messageSetter
 }
 
 // NewPOLoader factory method.
@@ -52,22 +69,183 @@ func (ldr *POLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime t
 	}
 
 	tagStr := tag.String()
-	ldr.catalogsByTagStr[tagStr] = NewStringCatalog(modTime)
+	cat := NewStringCatalog(modTime)
+	ldr.catalogsByTagStr[tagStr] = cat
 
-	re := regexp.MustCompile(pattern)
-	matches := re.FindAllStringSubmatch(string(buf), -1)
+	matches := pattern.FindAllStringSubmatch(string(buf), -1)
 
-	pairs := map[string]string{}
 	for _, array := range matches {
+		id, translation := poUnescape(array[3]), poUnescape(array[6])
+		meta := poComments(array[1])
+		if array[2] != "" {
+			meta[MetadataContext] = poUnescape(array[2])
+		}
+
+		if array[5] != "" {
+			forms := pluralFormPattern.FindAllStringSubmatch(array[5], -1)
+			categories := poPluralCategories(len(forms))
+			for i, form := range forms {
+				meta[MetadataPluralPrefix+categories[i]] = poUnescape(form[1])
+			}
+			meta[MetadataPluralID] = poUnescape(array[4])
+			translation = poUnescape(forms[len(forms)-1][1])
+		}
+
+		if translation == "" {
+			continue
+		}
 		log.Debug().Str("languagetag", tagStr).
-			Str("id", array[1]).
-			Str("translation", array[2]).
+			Str("id", id).
+			Str("translation", translation).
 			Msg("Loading string")
-		message.SetString(*tag, array[1], array[2])
+		ldr.setString(*tag, id, translation)
 
-		ldr.catalogsByTagStr[tagStr].Strings[array[1]] = array[2]
-		pairs[array[1]] = array[2]
+		// A later entry with the msgid, in another context, replaces the earlier one along with its metadata.
+		cat.Strings[id] = translation
+		if len(meta) > 0 {
+			cat.Metadata[id] = meta
+		} else {
+			delete(cat.Metadata, id)
+		}
 	}
 
 	return nil
 }
+
+// poComments gets the metadata in the comment lines above an entry.
+func poComments(lines string) map[string]string {
+	meta := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(lines, "\n"), "\n") {
+		var key string
+		switch {
+		case strings.HasPrefix(line, "#."):
+			key, line = MetadataComment, line[2:]
+		case line == "#" || strings.HasPrefix(line, "# "):
+			key, line = MetadataTranslatorComment, line[1:]
+		default:
+			// References, flags and obsolete entries aren't kept.
+			continue
+		}
+		line = strings.TrimPrefix(line, " ")
+		if meta[key] != "" {
+			line = meta[key] + "\n" + line
+		}
+		meta[key] = line
+	}
+	return meta
+}
+
+// poPluralCategories guesses the CLDR categories of the forms of a plural entry. Forms are numbered
+// when there are more than two of them, as telling which is which needs the Plural-Forms expression.
+func poPluralCategories(n int) []string {
+	switch n {
+	case 1:
+		return []string{"other"}
+	case 2:
+		return []string{"one", "other"}
+	}
+	categories := []string{}
+	for i := 0; i < n; i++ {
+		categories = append(categories, strconv.Itoa(i))
+	}
+	return categories
+}
+
+// poEntryKey identifies a PO entry by its context, if any, and msgid.
+type poEntryKey struct {
+	context string
+	id      string
+}
+
+// EditFile implements the FileEditor interface.
+// A key is the entry ReadMessages loads it from: the last translated one with that msgid, whatever its context,
+// or else the first. Other entries with the msgid in other contexts are left alone.
+// Deleting a key also removes the comments and context above its entry. New entries go at the end of the file.
+func (ldr *POLoader) EditFile(path string, data []byte, tag language.Tag, edits []Edit, add bool) ([]byte, []Edit, error) {
+	pending := newPendingEdits(edits)
+	for _, m := range pluralIDPattern.FindAllSubmatch(data, -1) {
+		if _, ok := pending.byKey[poUnescape(string(m[1]))]; ok {
+			return nil, nil, fmt.Errorf("%w: %s has plural forms", ErrNotEditable, poUnescape(string(m[1])))
+		}
+	}
+
+	matches := entryPattern.FindAllSubmatchIndex(data, -1)
+	entryKey := func(m []int) poEntryKey {
+		k := poEntryKey{id: poUnescape(string(data[m[6]:m[7]]))}
+		if m[4] >= 0 {
+			k.context = poUnescape(string(data[m[4]:m[5]]))
+		}
+		return k
+	}
+	loaded := map[string]poEntryKey{}
+	for _, m := range matches {
+		k := entryKey(m)
+		if _, ok := loaded[k.id]; !ok || m[9] > m[8] {
+			loaded[k.id] = k
+		}
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, m := range matches {
+		k := entryKey(m)
+		if loaded[k.id] != k {
+			continue
+		}
+		e, ok := pending.take(k.id)
+		if !ok {
+			continue
+		}
+
+		if e.Delete {
+			out.Write(data[last:m[0]])
+			last = m[1]
+			// Take the blank line that separated the entry from the next one with it.
+			for i := 0; i < 2 && last < len(data) && data[last] == '\n'; i++ {
+				last++
+			}
+			if last == len(data) {
+				// It was the last entry, so drop the blank line before it instead.
+				trimmed := bytes.TrimRight(out.Bytes(), "\n")
+				out.Truncate(len(trimmed))
+				if len(trimmed) > 0 {
+					out.WriteString("\n")
+				}
+			}
+		} else {
+			out.Write(data[last:m[8]])
+			out.WriteString(poEscaper.Replace(e.Value))
+			last = m[9]
+		}
+	}
+	out.Write(data[last:])
+
+	rest := pending.rest()
+	if !add {
+		return out.Bytes(), rest, nil
+	}
+
+	remaining := []Edit{}
+	for _, e := range rest {
+		if e.Delete {
+			remaining = append(remaining, e)
+			continue
+		}
+		if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteString("\n")
+		}
+		out.WriteString("\nmsgid \"" + poEscaper.Replace(e.Key) + "\"\nmsgstr \"" + poEscaper.Replace(e.Value) + "\"\n")
+	}
+	return out.Bytes(), remaining, nil
+}
+
+// poUnescape decodes the C-style escapes in a PO string, leaving it as it is if they aren't valid.
+func poUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	if u, err := strconv.Unquote(`"` + s + `"`); err == nil {
+		return u
+	}
+	return s
+}
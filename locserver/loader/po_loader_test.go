@@ -1,6 +1,7 @@
 package loader
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -84,3 +85,129 @@ func TestMultiplePOLoad(t *testing.T) {
 	assert.Equal(t, "chinese foo", p.Sprintf("foo"))
 	assert.Equal(t, "chinese bar", p.Sprintf("bar"))
 }
+
+func TestPOEditFile(t *testing.T) {
+	data := "msgid \"\"\nmsgstr \"\"\n" + header + `
+# Shown on the home page.
+msgid "greeting"
+msgstr "hello"
+
+#. Shown when leaving.
+msgid "farewell"
+msgstr "bye"
+
+msgid "untranslated"
+msgstr ""
+`
+
+	ldr := NewPOLoader()
+	out, rest, err := ldr.EditFile("messages.po", []byte(data), language.AmericanEnglish, []Edit{
+		{Key: "greeting", Value: `say "hi"`},
+		{Key: "farewell", Delete: true},
+		{Key: "untranslated", Value: "translated"},
+		{Key: "new", Value: "not added"},
+	}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []Edit{{Key: "new", Value: "not added"}}, rest)
+	assert.Equal(t, "msgid \"\"\nmsgstr \"\"\n"+header+`
+# Shown on the home page.
+msgid "greeting"
+msgstr "say \"hi\""
+
+msgid "untranslated"
+msgstr "translated"
+`, string(out))
+
+	out, rest, err = ldr.EditFile("messages.po", out, language.AmericanEnglish, []Edit{
+		{Key: "new", Value: "added"},
+		{Key: "untranslated", Delete: true},
+		{Key: "missing", Delete: true},
+	}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []Edit{{Key: "missing", Delete: true}}, rest)
+	assert.True(t, strings.HasSuffix(string(out), "msgstr \"say \\\"hi\\\"\"\n\nmsgid \"new\"\nmsgstr \"added\"\n"), string(out))
+
+	err = ldr.ReadMessages(strings.NewReader(string(out)), &language.AmericanEnglish, time.Now())
+	assert.Nil(t, err)
+	cat, _ := ldr.StringsByTag(language.AmericanEnglish)
+	assert.Equal(t, map[string]string{"greeting": `say "hi"`, "new": "added"}, cat.Strings)
+}
+
+func TestPOEditFileContexts(t *testing.T) {
+	data := "msgid \"\"\nmsgstr \"\"\n" + header + `
+#. menu item
+msgctxt "menu"
+msgid "open"
+msgstr "Öffnen"
+
+msgctxt "door"
+msgid "close"
+msgstr "zumachen"
+
+msgid "close"
+msgstr "Schließen"
+`
+
+	ldr := NewPOLoader()
+	out, rest, err := ldr.EditFile("messages.po", []byte(data), language.German, []Edit{
+		{Key: "open", Delete: true},
+		{Key: "close", Value: "Schließen!"},
+	}, false)
+	assert.Nil(t, err)
+	assert.Empty(t, rest)
+	// The context and comment of a deleted entry go with it, and only the loaded entry of a msgid is edited.
+	assert.Equal(t, "msgid \"\"\nmsgstr \"\"\n"+header+`
+msgctxt "door"
+msgid "close"
+msgstr "zumachen"
+
+msgid "close"
+msgstr "Schließen!"
+`, string(out))
+
+	err = ldr.ReadMessages(strings.NewReader(string(out)), &language.German, time.Now())
+	assert.Nil(t, err)
+	cat, _ := ldr.StringsByTag(language.German)
+	assert.Equal(t, map[string]string{"close": "Schließen!"}, cat.Strings)
+	assert.Empty(t, cat.Metadata["close"])
+}
+
+func TestPOLoadMetadataAndPlurals(t *testing.T) {
+	data := header + `
+#. Shown on the home page.
+# Reviewed.
+#: home.go:12
+msgctxt "home"
+msgid "greeting"
+msgstr "witaj"
+
+msgid "file"
+msgid_plural "files"
+msgstr[0] "plik"
+msgstr[1] "pliki"
+msgstr[2] "plików"
+`
+
+	ldr := NewPOLoader()
+	Isolate(ldr)
+	tag := language.MustParse("pl-pl")
+	err := ldr.ReadMessages(strings.NewReader(data), &tag, time.Now())
+	assert.Nil(t, err)
+
+	cat, _ := ldr.StringsByTag(tag)
+	assert.Equal(t, map[string]string{"greeting": "witaj", "file": "plików"}, cat.Strings)
+	assert.Equal(t, map[string]string{
+		MetadataComment:           "Shown on the home page.",
+		MetadataTranslatorComment: "Reviewed.",
+		MetadataContext:           "home",
+	}, cat.Metadata["greeting"])
+	assert.Equal(t, map[string]string{
+		MetadataPluralPrefix + "0": "plik",
+		MetadataPluralPrefix + "1": "pliki",
+		MetadataPluralPrefix + "2": "plików",
+		MetadataPluralID:           "files",
+	}, cat.Metadata["file"])
+
+	_, _, err = ldr.EditFile("pl.po", []byte(data), tag, []Edit{{Key: "file", Value: "pliki"}}, true)
+	assert.True(t, errors.Is(err, ErrNotEditable))
+}
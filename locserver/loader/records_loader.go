@@ -0,0 +1,110 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+)
+
+// Record is one message in the records format.
+type Record struct {
+	Tag      string            `json:"tag"`
+	Key      string            `json:"key"`
+	Value    string            `json:"value"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// RecordsLoader loads strings from a stream of JSON objects, one per message, such as a JSON Lines file:
+//
+//	{"tag": "en-us", "key": "greeting", "value": "Hello!", "metadata": {"comment": "..."}}
+//
+// tag may be omitted, in which case the tag of the locale directory is used.
+// This is also the format ExecLoader commands write and sqlitestore.Store snapshots use.
+type RecordsLoader struct {
+	catalogsByTagStr map[string]*StringCatalog
+	messageSetter
+}
+
+// NewRecordsLoader factory method.
+func NewRecordsLoader() *RecordsLoader {
+	return &RecordsLoader{
+		catalogsByTagStr: map[string]*StringCatalog{},
+	}
+}
+
+// StringsByTag gets the string table for the given language tag.
+func (ldr *RecordsLoader) StringsByTag(tag language.Tag) (*StringCatalog, error) {
+	if cat, ok := ldr.catalogsByTagStr[tag.String()]; ok {
+		return cat, nil
+	}
+	return nil, errors.New("catalog not found for tag " + tag.String())
+}
+
+// NeedsTag implements the Loader interface.
+func (ldr *RecordsLoader) NeedsTag() bool {
+	// Needed because records may leave the language out.
+	return true
+}
+
+// ReadMessages implements the Loader interface.
+// Catalogs are only replaced if the whole stream parses.
+func (ldr *RecordsLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	catalogs := map[string]*StringCatalog{}
+	tags := map[string]language.Tag{}
+	dec := json.NewDecoder(reader)
+	for i := 0; ; i++ {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("bad record %d: %v", i, err)
+		}
+		if rec.Key == "" {
+			return fmt.Errorf("record %d has no key", i)
+		}
+
+		var t language.Tag
+		if rec.Tag != "" {
+			t, err = language.Parse(rec.Tag)
+			if err != nil {
+				return fmt.Errorf("record %d: %v", i, err)
+			}
+		} else if tag != nil && *tag != language.Und {
+			t = *tag
+		} else {
+			return fmt.Errorf("record %d has no tag", i)
+		}
+
+		tagStr := t.String()
+		cat, ok := catalogs[tagStr]
+		if !ok {
+			cat = NewStringCatalog(modTime)
+			catalogs[tagStr] = cat
+			tags[tagStr] = t
+		}
+
+		cat.Strings[rec.Key] = rec.Value
+		if len(rec.Metadata) > 0 {
+			cat.Metadata[rec.Key] = rec.Metadata
+		}
+	}
+
+	for tagStr, cat := range catalogs {
+		for id, translation := range cat.Strings {
+			log.Debug().Str("languagetag", tagStr).
+				Str("id", id).
+				Str("translation", translation).
+				Msg("Loading string")
+			ldr.setString(tags[tagStr], id, translation)
+		}
+		ldr.catalogsByTagStr[tagStr] = cat
+	}
+	return nil
+}
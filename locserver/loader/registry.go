@@ -0,0 +1,105 @@
+package loader
+
+import (
+	"errors"
+	"sync"
+)
+
+// Factory creates a new, empty Loader.
+type Factory func() Loader
+
+type registration struct {
+	name    string
+	factory Factory
+	match   FileMatcher
+}
+
+var (
+	registryMu    sync.RWMutex
+	registrations []registration
+)
+
+func init() {
+	// Order matters for auto-detection: the ARB matcher sniffs .json files, so it must come before gotext.
+	Register("po", func() Loader { return NewPOLoader() }, MatchExtensions(".po"))
+	Register("xliff2", func() Loader { return NewXLIFF2Loader() }, MatchExtensions(".xlf", ".xliff"))
+	Register("arb", func() Loader { return NewARBLoader() }, MatchExtensions(".arb"), MatchContent(`"`+arbLocaleKey+`"`, ".json"))
+	Register("gotext", func() Loader { return NewGoTextJSONLoader() }, MatchExtensions(".json"))
+	Register("records", func() Loader { return NewRecordsLoader() }, MatchExtensions(".jsonl", ".ndjson"))
+	Register("csv", func() Loader { return NewCSVLoader() }, MatchExtensions(".csv"))
+	Register("mo", func() Loader { return NewMOLoader() }, MatchExtensions(".mo"))
+	Register("yaml", func() Loader { return NewYAMLLoader() }, MatchExtensions(".yaml", ".yml"))
+	Register("auto", func() Loader { return NewAutoLoader() })
+}
+
+// Register makes a loader available by name, typically from the init function of the package
+// that implements it. If matchers are given, the loader also takes part in auto-detection for
+// files accepted by any of them; loaders registered earlier take precedence.
+// Register panics if name is empty or already registered.
+func Register(name string, factory Factory, matchers ...FileMatcher) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" || factory == nil {
+		panic("loader: Register requires a name and a factory")
+	}
+	for _, r := range registrations {
+		if r.name == name {
+			panic("loader: Register called twice for loader " + name)
+		}
+	}
+
+	var match FileMatcher
+	if len(matchers) > 0 {
+		match = MatchAny(matchers...)
+	}
+	registrations = append(registrations, registration{
+		name:    name,
+		factory: factory,
+		match:   match,
+	})
+}
+
+// Names gets the names of all registered loaders, in registration order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registrations))
+	for _, r := range registrations {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+// New creates a new instance of the loader registered with the given name.
+func New(name string) (Loader, error) {
+	registryMu.RLock()
+	var factory Factory
+	for _, r := range registrations {
+		if r.name == name {
+			factory = r.factory
+			break
+		}
+	}
+	registryMu.RUnlock()
+
+	if factory == nil {
+		return nil, errors.New("unknown loader type " + name)
+	}
+	return factory(), nil
+}
+
+// autoDetected gets the registrations that take part in auto-detection.
+func autoDetected() []registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	regs := []registration{}
+	for _, r := range registrations {
+		if r.match != nil {
+			regs = append(regs, r)
+		}
+	}
+	return regs
+}
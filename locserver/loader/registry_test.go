@@ -0,0 +1,105 @@
+package loader
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+// upperLoader is a stand-in for a third-party format: one "key=value" per line, values upper-cased.
+type upperLoader struct {
+	cat *StringCatalog
+}
+
+func (ldr *upperLoader) StringsByTag(tag language.Tag) (*StringCatalog, error) {
+	if ldr.cat == nil {
+		return nil, errors.New("catalog not found for tag " + tag.String())
+	}
+	return ldr.cat, nil
+}
+
+func (ldr *upperLoader) NeedsTag() bool {
+	return true
+}
+
+func (ldr *upperLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	ldr.cat = NewStringCatalog(modTime)
+	for _, line := range strings.Split(string(data), "\n") {
+		if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+			ldr.cat.Strings[kv[0]] = strings.ToUpper(kv[1])
+		}
+	}
+	return nil
+}
+
+// unregister removes a loader registered by a test, so it doesn't take part in later tests.
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for i, r := range registrations {
+		if r.name == name {
+			registrations = append(registrations[:i:i], registrations[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestRegistryBuiltins(t *testing.T) {
+	names := Names()
+	for _, name := range []string{"gotext", "xliff2", "po", "arb", "auto"} {
+		assert.Contains(t, names, name)
+	}
+
+	ldr, err := New("po")
+	assert.Nil(t, err)
+	assert.IsType(t, &POLoader{}, ldr)
+
+	// Every call gets a fresh instance.
+	ldr2, _ := New("po")
+	assert.NotSame(t, ldr, ldr2)
+
+	_, err = New("nope")
+	assert.NotNil(t, err)
+}
+
+func TestRegistryThirdParty(t *testing.T) {
+	Register("upper-test", func() Loader { return &upperLoader{} }, MatchExtensions(".upper"))
+	t.Cleanup(func() { unregister("upper-test") })
+
+	assert.Contains(t, Names(), "upper-test")
+	assert.Panics(t, func() {
+		Register("upper-test", func() Loader { return &upperLoader{} })
+	})
+
+	ldr := NewAutoLoader()
+	enTag := language.MustParse("en-us")
+	err := ldr.ReadFile("en-us/strings.upper", strings.NewReader("foo=shout"), &enTag, time.Now())
+	assert.Nil(t, err)
+
+	cat, err := ldr.StringsByTag(enTag)
+	assert.Nil(t, err)
+	assert.Equal(t, "SHOUT", cat.Strings["foo"])
+	assert.Equal(t, "upper-test", ldr.Report()[0].Loader)
+}
+
+func TestRegistryUnregister(t *testing.T) {
+	Register("unregister-test", func() Loader { return &upperLoader{} }, MatchExtensions(".unregister"))
+	unregister("unregister-test")
+
+	assert.NotContains(t, Names(), "unregister-test")
+	for _, r := range autoDetected() {
+		assert.NotEqual(t, "unregister-test", r.name)
+	}
+	_, err := New("unregister-test")
+	assert.NotNil(t, err)
+}
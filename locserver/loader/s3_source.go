@@ -0,0 +1,379 @@
+package loader
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultS3Endpoint is the endpoint used for s3:// locales if no other is configured.
+const DefaultS3Endpoint = "https://s3.amazonaws.com"
+
+// emptyPayloadHash is the SHA-256 of an empty request body, which is all this client sends.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// IsS3URL reports whether localesDir names an S3 bucket and prefix, as s3://bucket/prefix.
+func IsS3URL(localesDir string) bool {
+	return strings.HasPrefix(strings.ToLower(localesDir), "s3://")
+}
+
+// ParseS3URL splits an s3://bucket/prefix URL into its bucket and prefix.
+func ParseS3URL(s3URL string) (bucket string, prefix string, err error) {
+	if !IsS3URL(s3URL) {
+		return "", "", errors.New("not an s3:// URL: " + s3URL)
+	}
+	parts := strings.SplitN(s3URL[len("s3://"):], "/", 2)
+	if parts[0] == "" {
+		return "", "", errors.New("no bucket in " + s3URL)
+	}
+	if len(parts) == 2 {
+		prefix = strings.Trim(parts[1], "/")
+	}
+	return parts[0], prefix, nil
+}
+
+// s3Object is an entry from a ListObjectsV2 response.
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+}
+
+type s3ListResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+// s3Cached is a downloaded object along with the ETag it had.
+type s3Cached struct {
+	etag string
+	file MemFile
+}
+
+// S3Source reads a locales tree from S3-compatible object storage, treating
+// <prefix>/<locale>/... as the locale directories. It polls the listing and only
+// downloads objects whose ETag has changed.
+//
+// Requests use path-style addressing, so that stand-ins like MinIO work, and are signed
+// with AWS Signature Version 4 if AccessKey is set.
+type S3Source struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// SessionToken is sent with temporary credentials, such as those of an assumed role.
+	SessionToken string
+	Interval     time.Duration
+	Client       *http.Client
+
+	mu       sync.Mutex
+	snapshot fs.FS
+	objects  map[string]s3Cached
+
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// NewS3Source is a factory method for S3Source. It loads the objects under prefix once, failing
+// if that doesn't succeed, and then polls every interval for changes. An interval of 0 disables polling.
+func NewS3Source(endpoint, region, bucket, prefix, accessKey, secretKey, sessionToken string, interval time.Duration) (*S3Source, error) {
+	src := &S3Source{
+		Endpoint:     strings.TrimRight(endpoint, "/"),
+		Region:       region,
+		Bucket:       bucket,
+		Prefix:       strings.Trim(prefix, "/"),
+		AccessKey:    accessKey,
+		SecretKey:    secretKey,
+		SessionToken: sessionToken,
+		Interval:     interval,
+		Client:       &http.Client{Timeout: time.Minute},
+		objects:      map[string]s3Cached{},
+		done:         make(chan struct{}),
+	}
+
+	if _, err := src.sync(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		src.changed = make(chan struct{}, 1)
+		go src.poll()
+	}
+	return src, nil
+}
+
+// Open implements the Source interface. It returns the last good snapshot.
+func (src *S3Source) Open() (fs.FS, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.snapshot, nil
+}
+
+// Changed implements the Source interface.
+func (src *S3Source) Changed() <-chan struct{} {
+	if src.changed == nil {
+		return nil
+	}
+	return src.changed
+}
+
+// Close implements the Source interface.
+func (src *S3Source) Close() error {
+	close(src.done)
+	return nil
+}
+
+func (src *S3Source) poll() {
+	for {
+		select {
+		case <-src.done:
+			return
+		case <-time.After(src.Interval):
+		}
+
+		updated, err := src.sync()
+		if err != nil {
+			log.Warn().Str("bucket", src.Bucket).Str("prefix", src.Prefix).Err(err).Msg("Error polling for locales, keeping last snapshot")
+			continue
+		}
+		if updated {
+			notify(src.changed)
+		}
+	}
+}
+
+// sync lists the prefix, downloads new and changed objects, and reports whether anything changed.
+func (src *S3Source) sync() (bool, error) {
+	listing, err := src.list()
+	if err != nil {
+		return false, err
+	}
+
+	src.mu.Lock()
+	previous := src.objects
+	src.mu.Unlock()
+
+	objects := map[string]s3Cached{}
+	updated := len(listing) != len(previous)
+	for _, obj := range listing {
+		if cached, ok := previous[obj.Key]; ok && cached.etag == obj.ETag {
+			objects[obj.Key] = cached
+			continue
+		}
+
+		data, err := src.get(obj.Key)
+		if err != nil {
+			return false, err
+		}
+		log.Debug().Str("bucket", src.Bucket).Str("key", obj.Key).Str("etag", obj.ETag).Msg("Fetched locale object")
+		objects[obj.Key] = s3Cached{etag: obj.ETag, file: MemFile{Data: data, ModTime: obj.LastModified}}
+		updated = true
+	}
+
+	if !updated {
+		return false, nil
+	}
+
+	files := map[string]MemFile{}
+	for key, cached := range objects {
+		files[strings.TrimPrefix(key, src.keyPrefix())] = cached.file
+	}
+	fsys, err := NewMemFS(files)
+	if err != nil {
+		return false, err
+	}
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.objects = objects
+	src.snapshot = fsys
+	return true, nil
+}
+
+func (src *S3Source) keyPrefix() string {
+	if src.Prefix == "" {
+		return ""
+	}
+	return src.Prefix + "/"
+}
+
+func (src *S3Source) list() ([]s3Object, error) {
+	objects := []s3Object{}
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", src.keyPrefix())
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		body, err := src.do("/"+src.Bucket, query)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("listing %s: %v", src.Bucket, err)
+		}
+		for _, obj := range result.Contents {
+			// Skip folder placeholder objects.
+			if !strings.HasSuffix(obj.Key, "/") {
+				objects = append(objects, obj)
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (src *S3Source) get(key string) ([]byte, error) {
+	return src.do("/"+src.Bucket+"/"+key, nil)
+}
+
+func (src *S3Source) do(path string, query url.Values) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, src.Endpoint+s3EscapePath(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = s3EncodeQuery(query)
+
+	if src.AccessKey != "" {
+		signS3Request(req, src.Region, src.AccessKey, src.SecretKey, src.SessionToken, time.Now())
+	}
+
+	res, err := src.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s: %s", path, res.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// signS3Request adds AWS Signature Version 4 headers to a request without a body.
+// A session token, if any, is sent and signed along with the other headers.
+func signS3Request(req *http.Request, region, accessKey, secretKey, sessionToken string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// s3EscapePath URI-encodes each segment of a path the way Signature Version 4 expects.
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = s3Escape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3EncodeQuery encodes a query string sorted by key, the way Signature Version 4 expects.
+func s3EncodeQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything except the RFC 3986 unreserved characters.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
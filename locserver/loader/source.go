@@ -0,0 +1,34 @@
+package loader
+
+import (
+	"io/fs"
+)
+
+// Source provides snapshots of a locales tree that may change over time, such as an archive
+// or a remote bundle. Each snapshot has the same directory-per-locale layout as LocalesDir.
+type Source interface {
+	// Open gets the current snapshot of the locales tree.
+	Open() (fs.FS, error)
+
+	// Changed returns a channel that receives a value whenever a new snapshot is available,
+	// or nil if the source never changes.
+	Changed() <-chan struct{}
+
+	// Close stops looking for changes and releases any resources.
+	Close() error
+}
+
+// Versioned is implemented by sources that can identify the snapshot they serve.
+type Versioned interface {
+	// Revision identifies the snapshot most recently returned by Open, such as a commit hash.
+	Revision() string
+}
+
+// notify does a non-blocking send on a Changed channel, so that a burst of changes
+// results in a single reload.
+func notify(changed chan struct{}) {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
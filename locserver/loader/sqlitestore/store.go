@@ -0,0 +1,269 @@
+// Package sqlitestore keeps catalogs in an SQLite database and serves them as a loader.Source.
+//
+// It uses the database/sql driver named sqlite3, which the program must register, for example by
+// importing github.com/mattn/go-sqlite3.
+package sqlitestore
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"golang.org/x/text/language"
+)
+
+// catalogFile is the name of the file each locale gets in a Store snapshot.
+const catalogFile = "catalog.jsonl"
+
+// timestampFormat is how updated_at is written, the same as the column default.
+const timestampFormat = "2006-01-02 15:04:05.000"
+
+// schema has a version that triggers bump on every change to catalog_entries, including writes
+// by other tools, so that polling only has to read one row.
+const schema = `CREATE TABLE IF NOT EXISTS catalog_entries (
+	locale     TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	value      TEXT NOT NULL,
+	metadata   TEXT NOT NULL DEFAULT '{}',
+	updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	PRIMARY KEY (locale, key)
+);
+CREATE TABLE IF NOT EXISTS catalog_version (
+	id      INTEGER PRIMARY KEY CHECK (id = 1),
+	version INTEGER NOT NULL
+);
+INSERT OR IGNORE INTO catalog_version (id, version) VALUES (1, 0);
+CREATE TRIGGER IF NOT EXISTS catalog_entries_inserted AFTER INSERT ON catalog_entries
+	BEGIN UPDATE catalog_version SET version = version + 1; END;
+CREATE TRIGGER IF NOT EXISTS catalog_entries_updated AFTER UPDATE ON catalog_entries
+	BEGIN UPDATE catalog_version SET version = version + 1; END;
+CREATE TRIGGER IF NOT EXISTS catalog_entries_deleted AFTER DELETE ON catalog_entries
+	BEGIN UPDATE catalog_version SET version = version + 1; END;`
+
+// Store keeps catalogs in an SQLite database, one row per locale and key.
+//
+// As a loader.Source, each locale appears as a <locale>/catalog.jsonl file for loader.RecordsLoader.
+// Changes are detected by polling catalog_version, which triggers on catalog_entries bump on every
+// insert, update and delete, since updated_at can't reveal deletes. Rows written directly by other
+// tools are picked up too.
+type Store struct {
+	DB       *sql.DB
+	Interval time.Duration
+
+	mu       sync.Mutex
+	snapshot fs.FS
+	version  int64
+
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// Open is a factory method for Store. It creates the database and table
+// if needed, and then polls every interval for changes. An interval of 0 disables polling.
+func Open(path string, interval time.Duration) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &Store{
+		DB:       db,
+		Interval: interval,
+		done:     make(chan struct{}),
+	}
+
+	if _, err := store.sync(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if interval > 0 {
+		store.changed = make(chan struct{}, 1)
+		go store.poll()
+	}
+	return store, nil
+}
+
+// Put adds or replaces the value and metadata of a key.
+func (store *Store) Put(tag language.Tag, key, value string, metadata map[string]string) error {
+	meta, err := marshalMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = store.DB.Exec(`INSERT OR REPLACE INTO catalog_entries (locale, key, value, metadata, updated_at)
+		VALUES (?, ?, ?, ?, ?)`, tag.String(), key, value, meta, time.Now().UTC().Format(timestampFormat))
+	return err
+}
+
+// Import copies every catalog loaded by the StringTable into the store, replacing any
+// existing values for the same keys. It returns the number of entries written.
+func (store *Store) Import(st *loader.StringTable) (int, error) {
+	tx, err := store.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO catalog_entries (locale, key, value, metadata, updated_at)
+		VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	count := 0
+	now := time.Now().UTC().Format(timestampFormat)
+	for _, tag := range st.Tags() {
+		cat, err := st.StringsByTag(tag)
+		if err != nil {
+			log.Warn().Str("language_tag", tag.String()).Err(err).Msg("No catalog to import")
+			continue
+		}
+		for key, value := range cat.Strings {
+			meta, err := marshalMetadata(cat.Metadata[key])
+			if err != nil {
+				return 0, err
+			}
+			if _, err := stmt.Exec(tag.String(), key, value, meta, now); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+
+	return count, tx.Commit()
+}
+
+// Open implements the loader.Source interface. It picks up any writes made since the last poll.
+func (store *Store) Open() (fs.FS, error) {
+	if _, err := store.sync(); err != nil {
+		return nil, err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.snapshot, nil
+}
+
+// Changed implements the loader.Source interface.
+func (store *Store) Changed() <-chan struct{} {
+	if store.changed == nil {
+		return nil
+	}
+	return store.changed
+}
+
+// Close implements the loader.Source interface.
+func (store *Store) Close() error {
+	close(store.done)
+	return store.DB.Close()
+}
+
+func (store *Store) poll() {
+	for {
+		select {
+		case <-store.done:
+			return
+		case <-time.After(store.Interval):
+		}
+
+		updated, err := store.sync()
+		if err != nil {
+			log.Warn().Err(err).Msg("Error polling catalog database, keeping last snapshot")
+			continue
+		}
+		if updated {
+			select {
+			case store.changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// sync rebuilds the snapshot if the table has changed, and reports whether it had.
+func (store *Store) sync() (bool, error) {
+	var version int64
+	err := store.DB.QueryRow(`SELECT version FROM catalog_version`).Scan(&version)
+	if err != nil {
+		return false, err
+	}
+
+	store.mu.Lock()
+	unchanged := store.snapshot != nil && version == store.version
+	store.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	fsys, err := store.export()
+	if err != nil {
+		return false, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.snapshot = fsys
+	store.version = version
+	return true, nil
+}
+
+// export renders every locale as a records file.
+func (store *Store) export() (fs.FS, error) {
+	rows, err := store.DB.Query(`SELECT locale, key, value, metadata, updated_at FROM catalog_entries ORDER BY locale, key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buffers := map[string]*bytes.Buffer{}
+	modTimes := map[string]time.Time{}
+	for rows.Next() {
+		var rec loader.Record
+		var meta string
+		var updatedAt time.Time
+		if err := rows.Scan(&rec.Tag, &rec.Key, &rec.Value, &meta, &updatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(meta), &rec.Metadata); err != nil {
+			log.Warn().Str("locale", rec.Tag).Str("key", rec.Key).Err(err).Msg("Ignoring bad metadata")
+		}
+
+		buf, ok := buffers[rec.Tag]
+		if !ok {
+			buf = &bytes.Buffer{}
+			buffers[rec.Tag] = buf
+		}
+		if err := json.NewEncoder(buf).Encode(rec); err != nil {
+			return nil, err
+		}
+		if updatedAt.After(modTimes[rec.Tag]) {
+			modTimes[rec.Tag] = updatedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	files := map[string]loader.MemFile{}
+	for locale, buf := range buffers {
+		files[locale+"/"+catalogFile] = loader.MemFile{Data: buf.Bytes(), ModTime: modTimes[locale]}
+	}
+	return loader.NewMemFS(files)
+}
+
+func marshalMetadata(metadata map[string]string) (string, error) {
+	if len(metadata) == 0 {
+		return "{}", nil
+	}
+	meta, err := json.Marshal(metadata)
+	return string(meta), err
+}
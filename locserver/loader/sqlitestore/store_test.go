@@ -0,0 +1,119 @@
+package sqlitestore
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestStoreImportAndServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := loader.NewStringTableFS(fstest.MapFS{
+		"ko-kr/messages.jsonl": &fstest.MapFile{Data: []byte(
+			`{"key": "greeting", "value": "annyeong", "metadata": {"comment": "informal"}}` + "\n" +
+				`{"key": "farewell", "value": "jal ga"}` + "\n")},
+		"vi-vn/messages.jsonl": &fstest.MapFile{Data: []byte(`{"key": "greeting", "value": "xin chao"}`)},
+	}, loader.NewRecordsLoader())
+	err = files.Load()
+	assert.Nil(t, err)
+
+	store, err := Open(filepath.Join(dir, "catalogs.db"), 20*time.Millisecond)
+	assert.Nil(t, err)
+
+	count, err := store.Import(files)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+
+	st, err := loader.NewStringTableSource(store, loader.NewRecordsLoader())
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+
+	cat, err := st.StringsByTag(language.MustParse("ko-kr"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"greeting": "annyeong", "farewell": "jal ga"}, cat.Strings)
+	assert.Equal(t, map[string]string{"comment": "informal"}, cat.Metadata["greeting"])
+	assert.False(t, cat.LastModTime.IsZero())
+
+	// Changes to the table are picked up without any file system events.
+	err = store.Put(language.MustParse("th-th"), "greeting", "sawasdee", nil)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		cat, err := st.StringsByTag(language.MustParse("th-th"))
+		return err == nil && cat.Strings["greeting"] == "sawasdee"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	tag, _ := st.MatchStrings("th")
+	assert.Equal(t, "th-TH", tag.String())
+}
+
+func TestStoreDetectsDirectUpdates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "catalogs.db"), 0)
+	assert.Nil(t, err)
+	defer store.Close()
+	assert.Nil(t, store.Changed())
+
+	err = store.Put(language.MustParse("en-us"), "greeting", "hi", nil)
+	assert.Nil(t, err)
+	updated, err := store.sync()
+	assert.Nil(t, err)
+	assert.True(t, updated)
+
+	updated, err = store.sync()
+	assert.Nil(t, err)
+	assert.False(t, updated)
+
+	// A direct update is a change even though it leaves updated_at and the row count alone.
+	_, err = store.DB.Exec(`UPDATE catalog_entries SET value = 'hello' WHERE key = 'greeting'`)
+	assert.Nil(t, err)
+	updated, err = store.sync()
+	assert.Nil(t, err)
+	assert.True(t, updated)
+
+	data, err := fs.ReadFile(mustSnapshot(t, store), "en-US/"+catalogFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"value":"hello"`)
+
+	// So is replacing a row with another in the same second.
+	_, err = store.DB.Exec(`DELETE FROM catalog_entries WHERE key = 'greeting'`)
+	assert.Nil(t, err)
+	_, err = store.DB.Exec(`INSERT INTO catalog_entries (locale, key, value) VALUES ('en-US', 'farewell', 'bye')`)
+	assert.Nil(t, err)
+	updated, err = store.sync()
+	assert.Nil(t, err)
+	assert.True(t, updated)
+
+	data, err = fs.ReadFile(mustSnapshot(t, store), "en-US/"+catalogFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"value":"bye"`)
+	assert.NotContains(t, string(data), `"value":"hello"`)
+}
+
+func mustSnapshot(t *testing.T, src loader.Source) fs.FS {
+	fsys, err := src.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fsys
+}
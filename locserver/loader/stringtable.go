@@ -2,12 +2,16 @@ package loader
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -15,14 +19,48 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"golang.org/x/text/language"
+	"golang.org/x/text/message"
 )
 
+// ErrReadOnly is returned when editing a StringTable that can't write to its locale files.
+var ErrReadOnly = errors.New("locales are read-only")
+
+// ErrNotFound is returned when editing a language or key that isn't loaded.
+var ErrNotFound = errors.New("not found")
+
 // StringTable loads all the languages from a base directory of locales.
 type StringTable struct {
+	// LocalesDir is the on-disk base directory, if any.
 	LocalesDir string
 	Matcher    *language.Matcher
 	Loader     Loader
 
+	// mu guards Matcher, Loader, fsys and catalogs against reloads; use MatchStrings and StringsByTag to read them
+	mu sync.RWMutex
+
+	// editMu serializes Update calls, which read, change and write whole files
+	editMu sync.Mutex
+
+	// tags are the loaded languages, in the order given to Matcher
+	tags []language.Tag
+
+	// catalogs are the catalogs of the loaded languages by tag, replaced as a whole by each full load
+	catalogs map[string]*StringCatalog
+
+	// revision identifies the loaded snapshot, for Versioned sources
+	revision string
+	loadedAt time.Time
+
+	// generation counts the loads of files and languages, so that anything derived from the catalogs can be invalidated
+	generation uint64
+
+	// fsys is the locales tree, rooted at the base directory
+	fsys fs.FS
+
+	// source provides new snapshots of fsys, if it isn't a plain directory
+	source Source
+	done   chan struct{}
+
 	// watcher looks for updates in the loc files
 	watcher *fsnotify.Watcher
 
@@ -35,25 +73,100 @@ func NewStringTable(localesDir string, watch bool, ldr Loader) (*StringTable, er
 	strs := &StringTable{
 		LocalesDir:   localesDir,
 		Loader:       ldr,
+		fsys:         os.DirFS(localesDir),
 		fileModTimes: map[string]time.Time{},
 	}
 
 	if watch {
-		watcher, err := fsnotify.NewWatcher()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create watcher on %s: %v", localesDir, err)
+		if err := strs.startWatcher(); err != nil {
+			return nil, err
 		}
-		strs.watcher = watcher
 	}
 
 	return strs, nil
 }
 
+// NewStringTableFS is a factory method for a StringTable that loads from a file system,
+// such as an embed.FS, instead of a directory on disk. fsys is not watched for changes.
+func NewStringTableFS(fsys fs.FS, ldr Loader) *StringTable {
+	return &StringTable{
+		Loader:       ldr,
+		fsys:         fsys,
+		fileModTimes: map[string]time.Time{},
+	}
+}
+
+// NewStringTableSource is a factory method for a StringTable that loads from a Source,
+// such as an archive, and reloads whenever the source changes.
+func NewStringTableSource(src Source, ldr Loader) (*StringTable, error) {
+	fsys, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	strs := NewStringTableFS(fsys, ldr)
+	strs.source = src
+	strs.done = make(chan struct{})
+	if v, ok := src.(Versioned); ok {
+		strs.revision = v.Revision()
+	}
+	return strs, nil
+}
+
+// WithOverrides layers the on-disk localesDir over the StringTable's file system, so that files
+// on disk take precedence over the ones it already has. localesDir does not need to exist.
+// If watch is true, the directories on disk are watched for changes.
+func (st *StringTable) WithOverrides(localesDir string, watch bool) error {
+	st.mu.Lock()
+	st.LocalesDir = localesDir
+	st.fsys = NewOverlayFS(os.DirFS(localesDir), st.fsys)
+	st.mu.Unlock()
+
+	if watch && st.watcher == nil {
+		return st.startWatcher()
+	}
+	return nil
+}
+
+func (st *StringTable) startWatcher() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher on %s: %v", st.LocalesDir, err)
+	}
+	st.watcher = watcher
+	return nil
+}
+
 // Load loads the languages from the configured local directory.
 func (st *StringTable) Load() error {
+	err := st.loadAll()
+	if err != nil {
+		return err
+	}
+
+	if st.watcher != nil {
+		go st.watch()
+	}
+	if st.source != nil && st.source.Changed() != nil {
+		go st.watchSource()
+	}
+
+	return nil
+}
+
+// files gets the locales tree being served.
+func (st *StringTable) files() fs.FS {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.fsys
+}
+
+// loadAll loads every language in the locales tree. The catalogs of languages that are no longer in it are dropped.
+func (st *StringTable) loadAll() error {
 	log.Info().Str("localesdir", st.LocalesDir).Msg("Loading locales")
 
-	files, err := ioutil.ReadDir(st.LocalesDir)
+	fsys := st.files()
+	files, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return err
 	}
@@ -70,7 +183,7 @@ func (st *StringTable) Load() error {
 		}
 		tags = append(tags, t)
 
-		err = st.loadMessagesFromDirectory(path.Join(st.LocalesDir, f.Name()))
+		err = st.loadMessagesFromDirectory(fsys, f.Name())
 		if err != nil {
 			log.Warn().Err(err).Str("locale", f.Name()).Msg("Error reading locale directory")
 		}
@@ -82,15 +195,284 @@ func (st *StringTable) Load() error {
 
 	log.Info().Interface("tags", tags).Msg("Creating matcher")
 	matcher := language.NewMatcher(tags)
+	st.mu.Lock()
 	st.Matcher = &matcher
+	st.tags = tags
+	st.catalogs = map[string]*StringCatalog{}
+	st.refreshCatalogs()
+	st.loadedAt = time.Now()
+	st.mu.Unlock()
 
-	if st.watcher != nil {
-		go st.watch()
+	return nil
+}
+
+// refreshCatalogs gets the catalogs of the loaded languages from Loader again, after it has read files,
+// and bumps the generation with them, so that no one sees the new generation with the old catalogs.
+// The caller must hold mu.
+func (st *StringTable) refreshCatalogs() {
+	st.generation++
+	for _, t := range st.tags {
+		if cat, err := st.Loader.StringsByTag(t); err == nil {
+			st.catalogs[t.String()] = cat
+		} else {
+			delete(st.catalogs, t.String())
+		}
 	}
+}
+
+func (st *StringTable) watchSource() {
+	for {
+		select {
+		case <-st.done:
+			return
+		case <-st.source.Changed():
+			log.Info().Msg("Source changed, reloading strings")
+			fsys, err := st.source.Open()
+			if err != nil {
+				log.Error().Err(err).Msg("Error opening source, not reloaded")
+				continue
+			}
+
+			st.mu.Lock()
+			st.fsys = fsys
+			st.mu.Unlock()
+			if err := st.loadAll(); err != nil {
+				log.Error().Err(err).Msg("Error reloading, not reloaded")
+				continue
+			}
+			if v, ok := st.source.(Versioned); ok {
+				st.mu.Lock()
+				st.revision = v.Revision()
+				st.mu.Unlock()
+			}
+		}
+	}
+}
+
+// MatchStrings gets the loaded language that best matches the given language strings,
+// as language.MatchStrings does with Matcher.
+func (st *StringTable) MatchStrings(lang ...string) (tag language.Tag, index int) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	tag, index = language.MatchStrings(*st.Matcher, lang...)
+	return st.loadedTag(tag, index), index
+}
+
+// Match finds the loaded language that best matches the desired ones, in order of preference.
+// Unlike Matcher, it returns the loaded tag itself, without extensions such as -u-rg-cazzzz for fr-CA.
+func (st *StringTable) Match(desired ...language.Tag) (tag language.Tag, index int, c language.Confidence) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	tag, index, c = (*st.Matcher).Match(desired...)
+	return st.loadedTag(tag, index), index, c
+}
+
+// loadedTag gets the loaded language at index, the one Matcher returned tag for. The caller must hold mu.
+func (st *StringTable) loadedTag(tag language.Tag, index int) language.Tag {
+	if index >= 0 && index < len(st.tags) {
+		return st.tags[index]
+	}
+	return tag
+}
+
+// StringsByTag gets the string catalog of a loaded language.
+func (st *StringTable) StringsByTag(tag language.Tag) (*StringCatalog, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	if cat, ok := st.catalogs[tag.String()]; ok {
+		return cat, nil
+	}
+	return nil, errors.New("catalog not found for tag " + tag.String())
+}
+
+// Tags gets the loaded languages.
+func (st *StringTable) Tags() []language.Tag {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]language.Tag{}, st.tags...)
+}
+
+// Revision identifies the loaded snapshot, such as a commit hash, if the source is Versioned.
+func (st *StringTable) Revision() string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.revision
+}
+
+// LoadedAt gets the time the languages were last (re)loaded in full.
+func (st *StringTable) LoadedAt() time.Time {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.loadedAt
+}
+
+// Generation changes whenever strings or languages are loaded. Read it before the strings derived from it.
+func (st *StringTable) Generation() uint64 {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.generation
+}
+
+// Update applies edits to a loaded language and writes them back to its locale files on disk, in their own formats.
+// A key is changed in the file that has it, and new keys are added to the first file that the Loader can edit.
+// Nothing is written unless every edit can be applied.
+func (st *StringTable) Update(tag language.Tag, edits []Edit) error {
+	editor, ok := st.Loader.(FileEditor)
+	if !ok || st.LocalesDir == "" || st.source != nil {
+		return ErrReadOnly
+	}
+
+	st.editMu.Lock()
+	defer st.editMu.Unlock()
 
+	dir, ok := st.localeDir(tag)
+	if !ok {
+		return fmt.Errorf("%w: language %s", ErrNotFound, tag)
+	}
+	var strs map[string]string
+	if cat, err := st.StringsByTag(tag); err == nil {
+		strs = cat.Strings
+	}
+	for _, e := range edits {
+		if _, ok := strs[e.Key]; e.Delete && !ok {
+			return fmt.Errorf("%w: key %q in %s", ErrNotFound, e.Key, tag)
+		}
+	}
+
+	editable := []string{}
+	original := map[string][]byte{}
+	contents := map[string][]byte{}
+	pending := edits
+	fsys := st.files()
+	err := fs.WalkDir(fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		out, rest, err := editor.EditFile(st.displayPath(name), data, tag, pending, false)
+		if errors.Is(err, ErrNotEditable) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		editable = append(editable, name)
+		original[name] = data
+		contents[name] = out
+		pending = rest
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		if len(editable) == 0 {
+			return fmt.Errorf("%w: no file in %s can be edited", ErrNotEditable, dir)
+		}
+		first := editable[0]
+		contents[first], pending, err = editor.EditFile(st.displayPath(first), contents[first], tag, pending, true)
+		if err != nil {
+			return fmt.Errorf("%s: %v", first, err)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: key %q in %s is not in an editable file", ErrNotEditable, pending[0].Key, tag)
+	}
+
+	for _, name := range editable {
+		if bytes.Equal(contents[name], original[name]) {
+			continue
+		}
+		if err := st.writeFile(name, contents[name]); err != nil {
+			return err
+		}
+		if err := st.loadMessagesFromFile(fsys, name); err != nil {
+			return err
+		}
+	}
+	st.mu.Lock()
+	st.refreshCatalogs()
+	st.mu.Unlock()
+
+	for _, e := range edits {
+		if e.Delete {
+			// The message catalog can't forget a string, so make it look untranslated.
+			message.SetString(tag, e.Key, e.Key)
+		}
+	}
 	return nil
 }
 
+// AddLocale saves a file for a language that isn't loaded yet as <tag>/<name> in the locales directory,
+// and reloads so that the language is served.
+func (st *StringTable) AddLocale(tag language.Tag, name string, data []byte) error {
+	if st.LocalesDir == "" || st.source != nil {
+		return ErrReadOnly
+	}
+	if name != path.Base(name) || !fs.ValidPath(name) || name == "." || strings.HasPrefix(name, ".") {
+		return errors.New("bad locale file name " + name)
+	}
+
+	st.editMu.Lock()
+	defer st.editMu.Unlock()
+
+	if dir, ok := st.localeDir(tag); ok {
+		return fmt.Errorf("language %s is already loaded from %s", tag, dir)
+	}
+	if err := st.writeFile(path.Join(strings.ToLower(tag.String()), name), data); err != nil {
+		return err
+	}
+	return st.loadAll()
+}
+
+// localeDir finds the directory of a loaded language.
+func (st *StringTable) localeDir(tag language.Tag) (string, bool) {
+	files, err := fs.ReadDir(st.files(), ".")
+	if err != nil {
+		return "", false
+	}
+	for _, f := range files {
+		if t, err := language.Parse(f.Name()); err == nil && f.IsDir() && t == tag {
+			return f.Name(), true
+		}
+	}
+	return "", false
+}
+
+// writeFile replaces a locale file on disk. The new contents are written to a temporary file in the
+// base directory, which isn't watched, and moved into place so that no one sees a partly written file.
+func (st *StringTable) writeFile(name string, data []byte) error {
+	p := st.osPath(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	mode := fs.FileMode(0644)
+	if stat, err := os.Stat(p); err == nil {
+		mode = stat.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(st.LocalesDir, ".edit-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
 func (st *StringTable) watch() {
 	done := make(chan bool)
 
@@ -100,23 +482,27 @@ func (st *StringTable) watch() {
 			// watch for events
 			case event := <-st.watcher.Events:
 				log.Info().Str("name", event.Name).Uint32("op", uint32(event.Op)).Msg("Reloading strings")
-				file, err := os.Open(event.Name)
+				name, err := st.fsPath(event.Name)
 				if err != nil {
-					log.Error().Str("name", event.Name).Err(err).Msg("Can't get file")
-					return
+					log.Error().Str("name", event.Name).Err(err).Msg("Not in locales dir")
+					continue
 				}
 
-				stat, err := file.Stat()
+				fsys := st.files()
+				stat, err := fs.Stat(fsys, name)
 				if err != nil {
 					log.Error().Str("name", event.Name).Err(err).Msg("Can't stat")
-					return
+					continue
 				}
 
 				if stat.IsDir() {
-					err = st.loadMessagesFromDirectory(event.Name)
+					err = st.loadMessagesFromDirectory(fsys, name)
 				} else {
-					err = st.loadMessagesFromFile(event.Name)
+					err = st.loadMessagesFromFile(fsys, name)
 				}
+				st.mu.Lock()
+				st.refreshCatalogs()
+				st.mu.Unlock()
 
 				if err != nil {
 					log.Error().Err(err).Msg("Error reloading, not reloaded")
@@ -134,16 +520,24 @@ func (st *StringTable) watch() {
 
 // Close deinitializes the StringTable.
 func (st *StringTable) Close() {
-	st.watcher.Close()
+	if st.watcher != nil {
+		st.watcher.Close()
+	}
+	if st.source != nil {
+		close(st.done)
+		st.source.Close()
+	}
 }
 
-func (st *StringTable) loadMessagesFromDirectory(dirname string) error {
-	files, err := ioutil.ReadDir(dirname)
+func (st *StringTable) loadMessagesFromDirectory(fsys fs.FS, dirname string) error {
+	files, err := fs.ReadDir(fsys, dirname)
 	if err != nil {
 		return err
 	}
 
-	st.watcher.Remove(dirname)
+	if st.watcher != nil {
+		st.watcher.Remove(st.osPath(dirname))
+	}
 
 	var result error
 
@@ -151,9 +545,9 @@ func (st *StringTable) loadMessagesFromDirectory(dirname string) error {
 		fullPath := path.Join(dirname, f.Name())
 
 		if f.IsDir() {
-			err = st.loadMessagesFromDirectory(fullPath) // recursive
+			err = st.loadMessagesFromDirectory(fsys, fullPath) // recursive
 		} else {
-			err = st.loadMessagesFromFile(fullPath)
+			err = st.loadMessagesFromFile(fsys, fullPath)
 		}
 
 		if err != nil {
@@ -162,18 +556,21 @@ func (st *StringTable) loadMessagesFromDirectory(dirname string) error {
 	}
 
 	if st.watcher != nil {
-		st.watcher.Add(dirname)
+		// Directories that only exist in an embedded file system can't be watched.
+		if _, err := os.Stat(st.osPath(dirname)); err == nil {
+			st.watcher.Add(st.osPath(dirname))
+		}
 	}
 
 	return result
 }
 
-func (st *StringTable) loadMessagesFromFile(fullPath string) error {
+func (st *StringTable) loadMessagesFromFile(fsys fs.FS, fullPath string) error {
 	var tag language.Tag
 	var err error
 	if st.Loader.NeedsTag() {
-		dirname := filepath.Dir(fullPath)
-		_, parentDir := filepath.Split(dirname)
+		dirname := path.Dir(fullPath)
+		_, parentDir := path.Split(dirname)
 		tagStr := parentDir
 
 		tag, err = language.Parse(tagStr)
@@ -182,17 +579,51 @@ func (st *StringTable) loadMessagesFromFile(fullPath string) error {
 		}
 	}
 
-	stat, err := os.Stat(fullPath)
+	stat, err := fs.Stat(fsys, fullPath)
 	if err != nil {
 		return err
 	}
 
-	file, err := os.Open(fullPath)
+	file, err := fsys.Open(fullPath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
 	reader := bufio.NewReader(file)
+	if fl, ok := st.Loader.(FileLoader); ok {
+		return fl.ReadFile(st.displayPath(fullPath), reader, &tag, stat.ModTime())
+	}
 	return st.Loader.ReadMessages(reader, &tag, stat.ModTime())
 }
+
+// osPath converts a path in the locales file system to a path on disk.
+func (st *StringTable) osPath(name string) string {
+	return filepath.Join(st.LocalesDir, filepath.FromSlash(name))
+}
+
+// fsPath converts a path on disk to a path in the locales file system.
+func (st *StringTable) fsPath(name string) (string, error) {
+	rel, err := filepath.Rel(st.LocalesDir, name)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if !fs.ValidPath(rel) {
+		return "", errors.New("path is outside of the locales directory: " + name)
+	}
+	return rel, nil
+}
+
+// displayPath gets the path on disk of a locale file if it has one, otherwise its path in the file system.
+func (st *StringTable) displayPath(name string) string {
+	if st.LocalesDir != "" {
+		if _, err := os.Stat(st.osPath(name)); err == nil {
+			return st.osPath(name)
+		}
+	}
+	return name
+}
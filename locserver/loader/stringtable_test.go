@@ -0,0 +1,214 @@
+package loader
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func poFile(id, translation string) *fstest.MapFile {
+	return &fstest.MapFile{
+		Data: []byte(header + "\nmsgid \"" + id + "\"\nmsgstr \"" + translation + "\"\n"),
+	}
+}
+
+func TestStringTableLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en-gb/messages.po": poFile("colour", "colour"),
+		"es-es/messages.po": poFile("colour", "color"),
+		"not-a-locale!/x":   poFile("colour", "nope"),
+	}
+
+	st := NewStringTableFS(fsys, NewPOLoader())
+	err := st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+
+	tag, _ := language.MatchStrings(*st.Matcher, "es")
+	assert.Equal(t, "es-ES", tag.String())
+
+	cat, err := st.Loader.StringsByTag(language.MustParse("es-es"))
+	assert.Nil(t, err)
+	assert.Equal(t, "color", cat.Strings["colour"])
+}
+
+func TestStringTableMatchLoadedTag(t *testing.T) {
+	st := NewStringTableFS(fstest.MapFS{
+		"en-gb/messages.po": poFile("colour", "colour"),
+		"es-es/messages.po": poFile("colour", "color"),
+	}, NewPOLoader())
+	assert.Nil(t, st.Load())
+	defer st.Close()
+
+	tag, _, _ := st.Match(language.MustParse("es-MX"))
+	assert.Equal(t, "es-ES", tag.String())
+	cat, err := st.StringsByTag(tag)
+	assert.Nil(t, err)
+	assert.Equal(t, "color", cat.Strings["colour"])
+
+	_, err = st.StringsByTag(language.MustParse("fr-FR"))
+	assert.NotNil(t, err)
+	// Only the loaded tag itself gets the catalog.
+	_, err = st.StringsByTag(language.MustParse("es-ES-u-rg-mxzzzz"))
+	assert.NotNil(t, err)
+}
+
+// snapshotSource is a Source that serves whichever snapshot was sent last.
+type snapshotSource struct {
+	current fs.FS
+	changed chan struct{}
+}
+
+func newSnapshotSource(fsys fs.FS) *snapshotSource {
+	return &snapshotSource{current: fsys, changed: make(chan struct{})}
+}
+
+func (src *snapshotSource) Open() (fs.FS, error) { return src.current, nil }
+
+func (src *snapshotSource) Changed() <-chan struct{} { return src.changed }
+
+func (src *snapshotSource) Close() error { return nil }
+
+func TestStringTableSourceDropsRemovedLocales(t *testing.T) {
+	src := newSnapshotSource(fstest.MapFS{
+		"en-gb/messages.po": poFile("colour", "colour"),
+		"es-es/messages.po": poFile("colour", "color"),
+	})
+	st, err := NewStringTableSource(src, NewPOLoader())
+	assert.Nil(t, err)
+	assert.Nil(t, st.Load())
+	defer st.Close()
+
+	_, err = st.StringsByTag(language.MustParse("es-es"))
+	assert.Nil(t, err)
+
+	src.current = fstest.MapFS{"en-gb/messages.po": poFile("colour", "color")}
+	src.changed <- struct{}{}
+	assert.Eventually(t, func() bool {
+		cat, err := st.StringsByTag(language.MustParse("en-gb"))
+		return err == nil && cat.Strings["colour"] == "color"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = st.StringsByTag(language.MustParse("es-es"))
+	assert.NotNil(t, err)
+	assert.Equal(t, []language.Tag{language.MustParse("en-gb")}, st.Tags())
+}
+
+func TestStringTableLoadFSOverrides(t *testing.T) {
+	embedded := fstest.MapFS{
+		"pt-br/messages.po": poFile("greeting", "embedded ola"),
+		"pt-pt/messages.po": poFile("greeting", "embedded ola pt"),
+	}
+
+	dir, err := ioutil.TempDir("", "stringtable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "pt-br"), 0755)
+	os.Mkdir(filepath.Join(dir, "it-it"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "pt-br", "messages.po"), poFile("greeting", "disk ola").Data, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "it-it", "messages.po"), poFile("greeting", "disk ciao").Data, 0644)
+
+	st := NewStringTableFS(embedded, NewPOLoader())
+	err = st.WithOverrides(dir, false)
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+
+	for lang, expected := range map[string]string{
+		"pt-br": "disk ola",
+		"pt-pt": "embedded ola pt",
+		"it-it": "disk ciao",
+	} {
+		cat, err := st.Loader.StringsByTag(language.MustParse(lang))
+		assert.Nil(t, err, lang)
+		assert.Equal(t, expected, cat.Strings["greeting"], lang)
+	}
+}
+
+func TestStringTableMissingOverrideDir(t *testing.T) {
+	embedded := fstest.MapFS{
+		"ja-jp/messages.po": poFile("greeting", "konnichiwa"),
+	}
+
+	st := NewStringTableFS(embedded, NewPOLoader())
+	err := st.WithOverrides(filepath.Join(os.TempDir(), "does-not-exist"), false)
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+
+	cat, err := st.Loader.StringsByTag(language.MustParse("ja-jp"))
+	assert.Nil(t, err)
+	assert.Equal(t, "konnichiwa", cat.Strings["greeting"])
+}
+
+func TestStringTableUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stringtable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "nl-nl"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "nl-nl", "messages.po"), poFile("greeting", "hallo").Data, 0600)
+	ioutil.WriteFile(filepath.Join(dir, "nl-nl", "README"), []byte("not a locale file"), 0644)
+
+	st, err := NewStringTable(dir, false, NewAutoLoader())
+	assert.Nil(t, err)
+	err = st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+
+	nl := language.MustParse("nl-nl")
+	err = st.Update(nl, []Edit{{Key: "greeting", Value: "goedendag"}, {Key: "farewell", Value: "tot ziens"}})
+	assert.Nil(t, err)
+
+	cat, err := st.StringsByTag(nl)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"greeting": "goedendag", "farewell": "tot ziens"}, cat.Strings)
+	assert.Equal(t, "tot ziens", getPrinter("nl-nl").Sprintf("farewell"))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "nl-nl", "messages.po"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), header)
+	assert.Contains(t, string(data), "msgid \"farewell\"\nmsgstr \"tot ziens\"\n")
+	stat, err := os.Stat(filepath.Join(dir, "nl-nl", "messages.po"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+	err = st.Update(nl, []Edit{{Key: "farewell", Delete: true}})
+	assert.Nil(t, err)
+	cat, _ = st.StringsByTag(nl)
+	assert.Equal(t, map[string]string{"greeting": "goedendag"}, cat.Strings)
+	assert.Equal(t, "farewell", getPrinter("nl-nl").Sprintf("farewell"))
+
+	err = st.Update(nl, []Edit{{Key: "greeting", Value: "hoi"}, {Key: "missing", Delete: true}})
+	assert.True(t, errors.Is(err, ErrNotFound))
+	cat, _ = st.StringsByTag(nl)
+	assert.Equal(t, "goedendag", cat.Strings["greeting"])
+
+	err = st.Update(language.MustParse("fy-nl"), []Edit{{Key: "greeting", Value: "goeie"}})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1, "temporary files are cleaned up")
+}
+
+func TestStringTableUpdateReadOnly(t *testing.T) {
+	st := NewStringTableFS(fstest.MapFS{"sv-se/messages.po": poFile("greeting", "hej")}, NewPOLoader())
+	err := st.Load()
+	assert.Nil(t, err)
+	defer st.Close()
+
+	err = st.Update(language.MustParse("sv-se"), []Edit{{Key: "greeting", Value: "tjena"}})
+	assert.Equal(t, ErrReadOnly, err)
+}
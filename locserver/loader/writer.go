@@ -0,0 +1,381 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Writer writes the catalog of one language in a file format. source is the language of the keys, for
+// formats that name it. It returns a warning for each kind of string or metadata in the catalog that the
+// format can't represent, which is left out.
+type Writer func(w io.Writer, tag, source language.Tag, cat *StringCatalog) (warnings []string, err error)
+
+// WriterFormat describes a registered Writer.
+type WriterFormat struct {
+	Name        string
+	ContentType string
+	// Extensions are the file extensions the format is used for, the usual one first.
+	Extensions []string
+	Write      Writer
+}
+
+var (
+	writersMu sync.RWMutex
+	writers   []WriterFormat
+)
+
+func init() {
+	RegisterWriter("po", WritePO, "text/x-gettext-translation", ".po")
+	RegisterWriter("xliff2", WriteXLIFF2, "application/xliff+xml", ".xlf", ".xliff")
+	RegisterWriter("gotext", WriteGoTextJSON, "application/x-gotext+json", ".json")
+	RegisterWriter("csv", WriteCSV, "text/csv", ".csv")
+	RegisterWriter("android", WriteAndroidXML, "application/x-android-strings+xml", ".xml")
+	RegisterWriter("strings", WriteAppleStrings, "text/x-apple-strings", ".strings")
+	RegisterWriter("arb", WriteARB, "application/x-arb+json", ".arb")
+	RegisterWriter("yaml", WriteYAML, "application/yaml", ".yaml", ".yml")
+	RegisterWriter("properties", WriteJavaProperties, "text/x-java-properties", ".properties")
+}
+
+// RegisterWriter makes a Writer available by name, with the content type it writes and the file extensions it is used for.
+// RegisterWriter panics if name is empty or already registered.
+func RegisterWriter(name string, write Writer, contentType string, exts ...string) {
+	writersMu.Lock()
+	defer writersMu.Unlock()
+
+	if name == "" || write == nil {
+		panic("loader: RegisterWriter requires a name and a writer")
+	}
+	for _, r := range writers {
+		if r.Name == name {
+			panic("loader: RegisterWriter called twice for writer " + name)
+		}
+	}
+	writers = append(writers, WriterFormat{Name: name, ContentType: contentType, Extensions: exts, Write: write})
+}
+
+// WriterNames gets the names of all registered writers, in registration order.
+func WriterNames() []string {
+	names := []string{}
+	for _, f := range Writers() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Writers gets all registered writers, in registration order.
+func Writers() []WriterFormat {
+	writersMu.RLock()
+	defer writersMu.RUnlock()
+	return append([]WriterFormat{}, writers...)
+}
+
+// LookupWriter gets a registered writer by name.
+func LookupWriter(name string) (WriterFormat, bool) {
+	for _, f := range Writers() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return WriterFormat{}, false
+}
+
+// WriterForPath gets the name of the first registered writer for the extension of path.
+func WriterForPath(path string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range Writers() {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// dropped counts the strings that lose each kind of metadata in a format, to warn about them once.
+type dropped map[string]int
+
+func (d dropped) warnings(format string) []string {
+	var warnings []string
+	for _, kind := range sortedKeys(d) {
+		warnings = append(warnings, fmt.Sprintf("%s can't represent %s, left out of %d strings", format, kind, d[kind]))
+	}
+	return warnings
+}
+
+// keep checks the metadata of a string against the keys a format can represent, counting the rest as dropped.
+// Plural forms are counted together.
+func (d dropped) keep(meta map[string]string, supported ...string) {
+	kinds := map[string]bool{}
+	for key := range meta {
+		ok := false
+		for _, s := range supported {
+			ok = ok || key == s || (s == MetadataPluralPrefix && strings.HasPrefix(key, MetadataPluralPrefix))
+		}
+		if ok {
+			continue
+		}
+		if strings.HasPrefix(key, MetadataPluralPrefix) || key == MetadataPluralID || key == MetadataPluralArg {
+			kinds["plural forms"] = true
+		} else {
+			kinds[strconv.Quote(key)+" metadata"] = true
+		}
+	}
+	for kind := range kinds {
+		d[kind]++
+	}
+}
+
+// pluralForms gets the plural forms of a string by category, in CLDR order, then by index, then explicit values.
+func pluralForms(meta map[string]string) (categories []string, forms []string) {
+	order := map[string]int{"zero": 0, "one": 1, "two": 2, "few": 3, "many": 4, "other": 5}
+	for key := range meta {
+		if strings.HasPrefix(key, MetadataPluralPrefix) {
+			categories = append(categories, strings.TrimPrefix(key, MetadataPluralPrefix))
+		}
+	}
+	rank := func(c string) (int, string) {
+		if r, ok := order[c]; ok {
+			return r, ""
+		}
+		if n, err := strconv.Atoi(c); err == nil {
+			return 10 + n, ""
+		}
+		return 1000, c
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		ri, si := rank(categories[i])
+		rj, sj := rank(categories[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return si < sj
+	})
+	for _, c := range categories {
+		forms = append(forms, meta[MetadataPluralPrefix+c])
+	}
+	return categories, forms
+}
+
+// poPluralRule is the Plural-Forms header of a language, and the CLDR categories of its forms in order.
+type poPluralRule struct {
+	categories string
+	forms      string
+}
+
+// poPluralRules are the rules of the languages whose gettext forms are CLDR categories, by base language.
+var poPluralRules = map[string]poPluralRule{}
+
+func init() {
+	for _, rule := range []struct {
+		poPluralRule
+		langs string
+	}{
+		{poPluralRule{"other", "nplurals=1; plural=0;"}, "id ja km ko lo ms my th vi zh"},
+		{poPluralRule{"one,other", "nplurals=2; plural=(n != 1);"}, "bg ca da de el en eo es et eu fi gl hu it nb nl nn no sv"},
+		{poPluralRule{"one,other", "nplurals=2; plural=(n > 1);"}, "fr"},
+	} {
+		for _, lang := range strings.Fields(rule.langs) {
+			poPluralRules[lang] = rule.poPluralRule
+		}
+	}
+}
+
+// WritePO writes a catalog as a gettext PO file.
+// Plural forms are written in CLDR order, with the Plural-Forms header of the language if it is known
+// and the forms match it.
+func WritePO(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	var buf bytes.Buffer
+	d := dropped{}
+	buf.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	buf.WriteString(`"Language: ` + strings.Replace(tag.String(), "-", "_", -1) + `\n"` + "\n")
+	buf.WriteString(`"MIME-Version: 1.0\n"` + "\n")
+	buf.WriteString(`"Content-Type: text/plain; charset=UTF-8\n"` + "\n")
+	buf.WriteString(`"Content-Transfer-Encoding: 8bit\n"` + "\n")
+	base, _ := tag.Base()
+	rule, hasRule := poPluralRules[base.String()]
+	if hasRule {
+		for _, meta := range cat.Metadata {
+			if _, forms := pluralForms(meta); len(forms) > 0 {
+				buf.WriteString(`"Plural-Forms: ` + rule.forms + `\n"` + "\n")
+				break
+			}
+		}
+	}
+
+	unusualPlurals := 0
+	for _, key := range sortedKeys(cat.Strings) {
+		meta := cat.Metadata[key]
+		d.keep(meta, MetadataComment, MetadataTranslatorComment, MetadataContext, MetadataPluralPrefix, MetadataPluralID)
+
+		buf.WriteString("\n")
+		writePOComment(&buf, "#. ", meta[MetadataComment])
+		writePOComment(&buf, "# ", meta[MetadataTranslatorComment])
+		if ctx, ok := meta[MetadataContext]; ok {
+			buf.WriteString("msgctxt \"" + poEscaper.Replace(ctx) + "\"\n")
+		}
+		buf.WriteString("msgid \"" + poEscaper.Replace(key) + "\"\n")
+
+		categories, forms := pluralForms(meta)
+		if len(forms) == 0 {
+			buf.WriteString("msgstr \"" + poEscaper.Replace(cat.Strings[key]) + "\"\n")
+			continue
+		}
+		if !hasRule || strings.Join(categories, ",") != rule.categories {
+			unusualPlurals++
+		}
+		pluralID := key
+		if id, ok := meta[MetadataPluralID]; ok {
+			pluralID = id
+		}
+		buf.WriteString("msgid_plural \"" + poEscaper.Replace(pluralID) + "\"\n")
+		for i, form := range forms {
+			buf.WriteString("msgstr[" + strconv.Itoa(i) + "] \"" + poEscaper.Replace(form) + "\"\n")
+		}
+	}
+
+	warnings := d.warnings("po")
+	if unusualPlurals > 0 {
+		if hasRule {
+			warnings = append(warnings, fmt.Sprintf("po Plural-Forms header for %s doesn't fit the plural forms of %d strings", tag, unusualPlurals))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("po has no Plural-Forms header for %s, which the plural forms of %d strings need", tag, unusualPlurals))
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return warnings, err
+}
+
+func writePOComment(buf *bytes.Buffer, prefix, comment string) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		buf.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+	}
+}
+
+// WriteXLIFF2 writes a catalog as an XLIFF 2 file, with each string in a unit of its own.
+// Plural forms can't be represented, so only the "other" form is written.
+func WriteXLIFF2(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	var buf bytes.Buffer
+	d := dropped{}
+	lang := strings.ToLower(tag.String())
+	buf.WriteString(`<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="` +
+		xmlEscape(strings.ToLower(source.String())) + `" trgLang="` + xmlEscape(lang) + "\">\n")
+	buf.WriteString(` <file id="` + xmlEscape(lang) + "\">\n")
+	for i, key := range sortedKeys(cat.Strings) {
+		meta := cat.Metadata[key]
+		d.keep(meta, MetadataComment, MetadataTranslatorComment, MetadataContext)
+
+		buf.WriteString(`  <unit id="u` + strconv.Itoa(i+1) + "\">\n")
+		notes := []string{}
+		for _, n := range []struct{ key, category string }{
+			{MetadataContext, xliffContextNote},
+			{MetadataComment, ""},
+			{MetadataTranslatorComment, xliffTranslatorNote},
+		} {
+			if v, ok := meta[n.key]; ok {
+				attr := ""
+				if n.category != "" {
+					attr = ` category="` + n.category + `"`
+				}
+				notes = append(notes, "    <note"+attr+">"+xmlEscape(v)+"</note>\n")
+			}
+		}
+		if len(notes) > 0 {
+			buf.WriteString("   <notes>\n" + strings.Join(notes, "") + "   </notes>\n")
+		}
+		buf.WriteString(`   <segment id="` + xmlEscape(key) + "\">\n")
+		buf.WriteString("    <source>" + xmlEscape(key) + "</source>\n")
+		buf.WriteString("    <target>" + xmlEscape(cat.Strings[key]) + "</target>\n")
+		buf.WriteString("   </segment>\n")
+		buf.WriteString("  </unit>\n")
+	}
+	buf.WriteString(" </file>\n</xliff>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return d.warnings("xliff2"), err
+}
+
+// WriteGoTextJSON writes a catalog in the JSON format supported by gotext.
+// Plural forms are written as a select, unless they are only numbered.
+func WriteGoTextJSON(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	d := dropped{}
+	lm := langmessages{Language: tag.String(), Messages: []langmessage{}}
+	for _, key := range sortedKeys(cat.Strings) {
+		meta := cat.Metadata[key]
+		m := langmessage{
+			ID:                key,
+			Message:           key,
+			Translation:       gotextTranslation{Msg: cat.Strings[key]},
+			Meaning:           meta[MetadataContext],
+			Comment:           meta[MetadataComment],
+			TranslatorComment: meta[MetadataTranslatorComment],
+		}
+
+		categories, forms := pluralForms(meta)
+		if len(forms) > 0 {
+			if _, err := strconv.Atoi(categories[0]); err == nil {
+				// Numbered PO forms; which is which depends on the Plural-Forms expression.
+				d.keep(meta, MetadataComment, MetadataTranslatorComment, MetadataContext)
+				lm.Messages = append(lm.Messages, m)
+				continue
+			}
+			m.Translation.Arg = meta[MetadataPluralArg]
+			m.Translation.Cases = map[string]string{}
+			for i, c := range categories {
+				m.Translation.Cases[c] = forms[i]
+			}
+		}
+		d.keep(meta, MetadataComment, MetadataTranslatorComment, MetadataContext, MetadataPluralPrefix, MetadataPluralArg)
+		lm.Messages = append(lm.Messages, m)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return d.warnings("gotext"), enc.Encode(lm)
+}
+
+// WriteCSV writes a catalog as CSV, with a header row and a column for each kind of metadata.
+func WriteCSV(w io.Writer, tag, source language.Tag, cat *StringCatalog) ([]string, error) {
+	kinds := map[string]bool{}
+	for _, meta := range cat.Metadata {
+		for k := range meta {
+			kinds[k] = true
+		}
+	}
+	columns := append([]string{"key", "value"}, sortedKeys(kinds)...)
+
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	for _, key := range sortedKeys(cat.Strings) {
+		row := []string{key, cat.Strings[key]}
+		for _, c := range columns[2:] {
+			row = append(row, cat.Metadata[key][c])
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return nil, cw.Error()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,132 @@
+package loader
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func testCatalog() *StringCatalog {
+	cat := NewStringCatalog(time.Time{})
+	cat.Strings["greeting"] = `Say "hi" & <wave>`
+	cat.Strings["files"] = "%d Dateien"
+	cat.Strings["plain"] = "einfach"
+	cat.Metadata["greeting"] = map[string]string{
+		MetadataComment:           "Shown on the home page.\nKeep it short.",
+		MetadataTranslatorComment: "Informal on purpose.",
+		MetadataContext:           "home",
+	}
+	cat.Metadata["files"] = map[string]string{
+		MetadataPluralPrefix + "one":   "%d Datei",
+		MetadataPluralPrefix + "other": "%d Dateien",
+	}
+	return cat
+}
+
+func roundTrip(t *testing.T, format, path string, cat *StringCatalog) (*StringCatalog, []string) {
+	f, ok := LookupWriter(format)
+	if !ok {
+		t.Fatal("no writer for " + format)
+	}
+	tag := language.MustParse("de-de")
+	var buf bytes.Buffer
+	warnings, err := f.Write(&buf, tag, language.AmericanEnglish, cat)
+	assert.Nil(t, err)
+
+	cats, err := ReadCatalogs(path, buf.Bytes(), &tag)
+	assert.Nil(t, err, buf.String())
+	assert.Contains(t, cats, tag, buf.String())
+	return cats[tag], warnings
+}
+
+func TestWritersRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		path     string
+		plurals  bool
+		warnings []string
+	}{
+		{format: "po", path: "de.po", plurals: true},
+		{format: "gotext", path: "de.json", plurals: true},
+		{format: "csv", path: "de.csv", plurals: true},
+		{
+			format:   "xliff2",
+			path:     "de.xlf",
+			warnings: []string{"xliff2 can't represent plural forms, left out of 1 strings"},
+		},
+	} {
+		cat := testCatalog()
+		read, warnings := roundTrip(t, tc.format, tc.path, cat)
+		assert.Equal(t, cat.Strings, read.Strings, tc.format)
+		assert.Equal(t, cat.Metadata["greeting"], read.Metadata["greeting"], tc.format)
+		_, forms := pluralForms(read.Metadata["files"])
+		if tc.plurals {
+			assert.Equal(t, []string{"%d Datei", "%d Dateien"}, forms, tc.format)
+		} else {
+			assert.Empty(t, forms, tc.format)
+		}
+		assert.Equal(t, tc.warnings, warnings, tc.format)
+	}
+}
+
+func TestWritersWarnAboutUnknownMetadata(t *testing.T) {
+	cat := testCatalog()
+	cat.Metadata["plain"] = map[string]string{"max_length": "10"}
+
+	_, warnings := roundTrip(t, "gotext", "de.json", cat)
+	assert.Equal(t, []string{`gotext can't represent "max_length" metadata, left out of 1 strings`}, warnings)
+
+	read, warnings := roundTrip(t, "csv", "de.csv", cat)
+	assert.Empty(t, warnings)
+	assert.Equal(t, "10", read.Metadata["plain"]["max_length"])
+}
+
+func TestWritePOPluralForms(t *testing.T) {
+	for _, tc := range []struct {
+		lang     string
+		header   string
+		warnings []string
+	}{
+		{lang: "de-de", header: `"Plural-Forms: nplurals=2; plural=(n != 1);\n"`},
+		{lang: "fr-ca", header: `"Plural-Forms: nplurals=2; plural=(n > 1);\n"`},
+		{
+			lang:     "ja-jp",
+			header:   `"Plural-Forms: nplurals=1; plural=0;\n"`,
+			warnings: []string{"po Plural-Forms header for ja-JP doesn't fit the plural forms of 1 strings"},
+		},
+		{lang: "pl-pl", warnings: []string{"po has no Plural-Forms header for pl-PL, which the plural forms of 1 strings need"}},
+	} {
+		var buf bytes.Buffer
+		warnings, err := WritePO(&buf, language.MustParse(tc.lang), language.AmericanEnglish, testCatalog())
+		assert.Nil(t, err)
+		assert.Equal(t, tc.warnings, warnings, tc.lang)
+		if tc.header != "" {
+			assert.Contains(t, buf.String(), tc.header, tc.lang)
+		} else {
+			assert.NotContains(t, buf.String(), "Plural-Forms", tc.lang)
+		}
+	}
+}
+
+func TestWriteXLIFF2SourceLanguage(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := WriteXLIFF2(&buf, language.MustParse("de-de"), language.MustParse("fr-ca"), testCatalog())
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), `srcLang="fr-ca" trgLang="de-de"`)
+}
+
+func TestWriterForPath(t *testing.T) {
+	name, ok := WriterForPath("out/messages.XLIFF")
+	assert.True(t, ok)
+	assert.Equal(t, "xliff2", name)
+
+	_, ok = WriterForPath("out/messages.txt")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() {
+		RegisterWriter("po", WritePO, "text/plain")
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
 )
 
 func TestSimpleXLIFF2Load(t *testing.T) {
@@ -92,3 +93,117 @@ func TestMultipleXLIFF2Load(t *testing.T) {
 	assert.Equal(t, "chinese foo", p.Sprintf("foo"))
 	assert.Equal(t, "chinese bar", p.Sprintf("bar"))
 }
+
+func TestXLIFF2EditFile(t *testing.T) {
+	data := `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en-us" trgLang="de-de">
+ <file id="de-de">
+  <!-- The home page. -->
+  <unit>
+   <segment id="greeting">
+    <source>hello</source>
+    <target>hallo</target>
+   </segment>
+   <segment id="farewell">
+    <source>bye</source>
+    <target>tschüss</target>
+   </segment>
+  </unit>
+  <unit>
+   <segment id="title">
+    <source>Home &amp; away</source>
+   </segment>
+  </unit>
+  <unit>
+   <segment id="gone">
+    <source>gone</source>
+    <target>weg</target>
+   </segment>
+  </unit>
+ </file>
+</xliff>
+`
+
+	ldr := NewXLIFF2Loader()
+	tag := language.MustParse("de-de")
+	out, rest, err := ldr.EditFile("de-de.xlf", []byte(data), tag, []Edit{
+		{Key: "greeting", Value: "guten <b>Tag</b>"},
+		{Key: "farewell", Delete: true},
+		{Key: "title", Value: "Heim & weg"},
+		{Key: "gone", Delete: true},
+		{Key: "new", Value: "neu"},
+		{Key: "missing", Delete: true},
+	}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []Edit{{Key: "missing", Delete: true}}, rest)
+	assert.Equal(t, `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en-us" trgLang="de-de">
+ <file id="de-de">
+  <!-- The home page. -->
+  <unit>
+   <segment id="greeting">
+    <source>hello</source>
+    <target>guten &lt;b&gt;Tag&lt;/b&gt;</target>
+   </segment>
+  </unit>
+  <unit>
+   <segment id="title">
+    <source>Home &amp; away</source>
+    <target>Heim &amp; weg</target>
+   </segment>
+  </unit>
+  <unit>
+   <segment id="new">
+    <source>new</source>
+    <target>neu</target>
+   </segment>
+  </unit>
+ </file>
+</xliff>
+`, string(out))
+
+	err = ldr.ReadMessages(strings.NewReader(string(out)), nil, time.Now())
+	assert.Nil(t, err)
+	cat, _ := ldr.StringsByTag(tag)
+	assert.Equal(t, map[string]string{"greeting": "guten <b>Tag</b>", "title": "Heim & weg", "new": "neu"}, cat.Strings)
+}
+
+func TestXLIFF2EditFileSelfClosingTarget(t *testing.T) {
+	data := `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en-us" trgLang="de-de">
+ <file id="de-de">
+  <unit>
+   <segment id="greeting">
+    <source>hello</source>
+    <target/>
+   </segment>
+   <segment id="farewell">
+    <source>bye</source>
+    <target xml:space="preserve"/>
+   </segment>
+  </unit>
+ </file>
+</xliff>
+`
+
+	ldr := NewXLIFF2Loader()
+	tag := language.MustParse("de-de")
+	out, rest, err := ldr.EditFile("de-de.xlf", []byte(data), tag, []Edit{
+		{Key: "greeting", Value: "hallo"},
+		{Key: "farewell", Value: " tschüss "},
+	}, false)
+	assert.Nil(t, err)
+	assert.Empty(t, rest)
+	assert.Equal(t, `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en-us" trgLang="de-de">
+ <file id="de-de">
+  <unit>
+   <segment id="greeting">
+    <source>hello</source>
+    <target>hallo</target>
+   </segment>
+   <segment id="farewell">
+    <source>bye</source>
+    <target xml:space="preserve"> tschüss </target>
+   </segment>
+  </unit>
+ </file>
+</xliff>
+`, string(out))
+}
@@ -1,15 +1,17 @@
 package loader
 
 import (
+	"bytes"
 	"encoding/xml"
 	"errors"
+	"html"
 	"io"
 	"io/ioutil"
+	"regexp"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
 // xliff is a stripped-down representation of the full XLIFF 2.0 schema.
@@ -24,7 +26,13 @@ type xliff struct {
 		Text string `xml:",chardata"`
 		ID   string `xml:"id,attr"`
 		Unit []struct {
-			Text    string `xml:",chardata"`
+			Text  string `xml:",chardata"`
+			Notes struct {
+				Note []struct {
+					Text     string `xml:",chardata"`
+					Category string `xml:"category,attr"`
+				} `xml:"note"`
+			} `xml:"notes"`
 			Segment []struct {
 				Text   string `xml:",chardata"`
 				ID     string `xml:"id,attr"`
@@ -35,9 +43,26 @@ type xliff struct {
 	} `xml:"file"`
 }
 
+// Note categories that are read as metadata other than MetadataComment.
+const (
+	xliffContextNote    = "context"
+	xliffTranslatorNote = "translator"
+)
+
+// These find the parts of an XLIFF 2 file that EditFile changes, so that everything else is left as it is.
+var (
+	unitPattern      = regexp.MustCompile(`(?s)[ \t]*<unit\b[^>]*>.*?</unit>[ \t]*\n?`)
+	segmentPattern   = regexp.MustCompile(`(?s)[ \t]*<segment\b([^>]*)>(.*?)</segment>[ \t]*\n?`)
+	idAttrPattern    = regexp.MustCompile(`\bid="([^"]*)"`)
+	targetPattern    = regexp.MustCompile(`(?s)<target\b([^>]*)>.*?</target>|<target\b([^>]*)/>`)
+	sourceEndPattern = regexp.MustCompile(`(?m)^([ \t]*)<source\b.*</source>`)
+	fileEndPattern   = regexp.MustCompile(`(?m)^([ \t]*)</file>`)
+)
+
 // XLIFF2Loader loads strings from files in the XLIFF 2 format.
 type XLIFF2Loader struct {
 	catalogsByTagStr map[string]*StringCatalog
+	messageSetter
 }
 
 // NewXLIFF2Loader factory method.
@@ -80,16 +105,148 @@ func (ldr *XLIFF2Loader) ReadMessages(reader io.Reader, tag *language.Tag, modTi
 	ldr.catalogsByTagStr[tagStr] = NewStringCatalog(modTime)
 
 	for _, u := range xlf.File.Unit {
+		meta := map[string]string{}
+		for _, n := range u.Notes.Note {
+			key := MetadataComment
+			switch n.Category {
+			case xliffContextNote:
+				key = MetadataContext
+			case xliffTranslatorNote:
+				key = MetadataTranslatorComment
+			}
+			if meta[key] != "" {
+				meta[key] += "\n"
+			}
+			meta[key] += n.Text
+		}
+
 		for _, seg := range u.Segment {
 			log.Debug().Str("languagetag", tagStr).
 				Str("id", seg.ID).
 				Str("translation", seg.Target).
 				Msg("Loading string")
-			message.SetString(t, seg.ID, seg.Target)
+			ldr.setString(t, seg.ID, seg.Target)
 
 			ldr.catalogsByTagStr[tagStr].Strings[seg.ID] = seg.Target
+			if len(meta) > 0 {
+				ldr.catalogsByTagStr[tagStr].Metadata[seg.ID] = meta
+			}
 		}
 	}
 
 	return nil
 }
+
+// EditFile implements the FileEditor interface.
+// Deleting the last segment of a unit removes the unit. New keys are added as units at the end of the last file element.
+func (ldr *XLIFF2Loader) EditFile(path string, data []byte, tag language.Tag, edits []Edit, add bool) ([]byte, []Edit, error) {
+	pending := newPendingEdits(edits)
+	var out bytes.Buffer
+	last := 0
+	for _, u := range unitPattern.FindAllIndex(data, -1) {
+		unit, emptied := editUnit(data[u[0]:u[1]], pending)
+		out.Write(data[last:u[0]])
+		if !emptied {
+			out.Write(unit)
+		}
+		last = u[1]
+	}
+	out.Write(data[last:])
+
+	rest := pending.rest()
+	if !add {
+		return out.Bytes(), rest, nil
+	}
+
+	var units bytes.Buffer
+	remaining := []Edit{}
+	loc := fileEndPattern.FindAllSubmatchIndex(out.Bytes(), -1)
+	for _, e := range rest {
+		if e.Delete {
+			remaining = append(remaining, e)
+			continue
+		}
+		if len(loc) == 0 {
+			return nil, nil, errors.New("no file element to add " + e.Key + " to")
+		}
+
+		indent := string(out.Bytes()[loc[len(loc)-1][2]:loc[len(loc)-1][3]])
+		step := indent
+		if step == "" {
+			step = "  "
+		}
+		units.WriteString(indent + step + "<unit>\n" +
+			indent + step + step + "<segment id=\"" + xmlEscape(e.Key) + "\">\n" +
+			indent + step + step + step + "<source>" + xmlEscape(e.Key) + "</source>\n" +
+			indent + step + step + step + "<target>" + xmlEscape(e.Value) + "</target>\n" +
+			indent + step + step + "</segment>\n" +
+			indent + step + "</unit>\n")
+	}
+	if units.Len() == 0 {
+		return out.Bytes(), remaining, nil
+	}
+
+	at := loc[len(loc)-1][0]
+	result := append([]byte{}, out.Bytes()[:at]...)
+	result = append(result, units.Bytes()...)
+	return append(result, out.Bytes()[at:]...), remaining, nil
+}
+
+// editUnit applies pending edits to the segments of a unit, returning the new unit and whether its segments were all deleted.
+func editUnit(unit []byte, pending *pendingEdits) ([]byte, bool) {
+	var out bytes.Buffer
+	last, segments, deleted := 0, 0, 0
+	for _, m := range segmentPattern.FindAllSubmatchIndex(unit, -1) {
+		segments++
+		id := idAttrPattern.FindSubmatch(unit[m[2]:m[3]])
+		if id == nil {
+			continue
+		}
+		e, ok := pending.take(html.UnescapeString(string(id[1])))
+		if !ok {
+			continue
+		}
+
+		out.Write(unit[last:m[0]])
+		last = m[1]
+		if e.Delete {
+			deleted++
+			continue
+		}
+		out.Write(unit[m[0]:m[4]])
+		out.Write(setTarget(unit[m[4]:m[5]], e.Value))
+		out.Write(unit[m[5]:m[1]])
+	}
+	out.Write(unit[last:])
+	return out.Bytes(), segments > 0 && deleted == segments
+}
+
+// setTarget replaces the target in the body of a segment, or adds one after the source.
+func setTarget(body []byte, value string) []byte {
+	if t := targetPattern.FindSubmatchIndex(body); t != nil {
+		var attrs []byte
+		if t[2] < 0 {
+			// A self-closing <target/>.
+			attrs = body[t[4]:t[5]]
+		} else {
+			attrs = body[t[2]:t[3]]
+		}
+		replaced := append([]byte{}, body[:t[0]]...)
+		replaced = append(replaced, "<target"+string(attrs)+">"+xmlEscape(value)+"</target>"...)
+		return append(replaced, body[t[1]:]...)
+	}
+
+	if s := sourceEndPattern.FindSubmatchIndex(body); s != nil {
+		indent := string(body[s[2]:s[3]])
+		added := append([]byte{}, body[:s[1]]...)
+		added = append(added, "\n"+indent+"<target>"+xmlEscape(value)+"</target>"...)
+		return append(added, body[s[1]:]...)
+	}
+	return append(append([]byte{}, body...), "<target>"+xmlEscape(value)+"</target>"...)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
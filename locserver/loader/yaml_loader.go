@@ -0,0 +1,153 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLLoader loads strings from YAML maps of keys to strings, as WriteYAML writes them.
+// Comments above a key become its comment, and a map of plural categories to strings its plural forms.
+// Other nested maps are flattened, joining their keys with dots. A file with a single top-level key
+// that is a language tag, as Rails uses, is read as the strings of that language.
+type YAMLLoader struct {
+	catalogsByTagStr map[string]*StringCatalog
+	messageSetter
+}
+
+// NewYAMLLoader factory method.
+func NewYAMLLoader() *YAMLLoader {
+	return &YAMLLoader{
+		catalogsByTagStr: map[string]*StringCatalog{},
+	}
+}
+
+// StringsByTag gets the string table for the given language tag.
+func (ldr *YAMLLoader) StringsByTag(tag language.Tag) (*StringCatalog, error) {
+	if cat, ok := ldr.catalogsByTagStr[tag.String()]; ok {
+		return cat, nil
+	}
+	return nil, errors.New("catalog not found for tag " + tag.String())
+}
+
+// NeedsTag implements the Loader interface.
+func (ldr *YAMLLoader) NeedsTag() bool {
+	// Needed because the language is usually not in the file.
+	return true
+}
+
+// ReadMessages implements the Loader interface.
+func (ldr *YAMLLoader) ReadMessages(reader io.Reader, tag *language.Tag, modTime time.Time) error {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(reader).Decode(&doc); err != nil && err != io.EOF {
+		return err
+	}
+	root := &doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind == 0 {
+		// An empty file.
+		root = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	if root.Kind != yaml.MappingNode {
+		return errors.New("YAML file must be a map of keys to strings")
+	}
+
+	var t language.Tag
+	if len(root.Content) == 2 && root.Content[1].Kind == yaml.MappingNode && !isPluralMap(root.Content[1]) {
+		if rootTag, err := language.Parse(root.Content[0].Value); err == nil {
+			t, root = rootTag, root.Content[1]
+		}
+	}
+	if t == language.Und {
+		if tag == nil || *tag == language.Und {
+			return errors.New("tag string is required by YAML loader")
+		}
+		t = *tag
+	}
+
+	tagStr := t.String()
+	cat := NewStringCatalog(modTime)
+	if err := yamlStrings(cat, root, ""); err != nil {
+		return err
+	}
+	for id, translation := range cat.Strings {
+		log.Debug().Str("languagetag", tagStr).
+			Str("id", id).
+			Str("translation", translation).
+			Msg("Loading string")
+		ldr.setString(t, id, translation)
+	}
+	ldr.catalogsByTagStr[tagStr] = cat
+
+	return nil
+}
+
+// yamlStrings adds the strings of a map to a catalog, with prefix before their keys.
+func yamlStrings(cat *StringCatalog, node *yaml.Node, prefix string) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		key := prefix + k.Value
+		meta := map[string]string{}
+		if c := yamlComment(k.HeadComment); c != "" {
+			meta[MetadataComment] = c
+		}
+
+		switch {
+		case v.Kind == yaml.ScalarNode:
+			cat.Strings[key] = v.Value
+		case isPluralMap(v):
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				meta[MetadataPluralPrefix+v.Content[j].Value] = v.Content[j+1].Value
+			}
+			if other, ok := meta[MetadataPluralPrefix+"other"]; ok {
+				cat.Strings[key] = other
+			} else {
+				cat.Strings[key] = v.Content[len(v.Content)-1].Value
+			}
+		case v.Kind == yaml.MappingNode:
+			if err := yamlStrings(cat, v, key+"."); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("line %d: %s is not a string or map", v.Line, key)
+		}
+		if len(meta) > 0 {
+			cat.Metadata[key] = meta
+		}
+	}
+	return nil
+}
+
+// isPluralMap checks whether a node is a map of CLDR plural categories to strings.
+func isPluralMap(node *yaml.Node) bool {
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !cldrCategories[node.Content[i].Value] || node.Content[i+1].Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlComment gets the text of comment lines.
+func yamlComment(comment string) string {
+	if comment == "" {
+		return ""
+	}
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(strings.TrimPrefix(line, "#"), " ")
+	}
+	return strings.Join(lines, "\n")
+}
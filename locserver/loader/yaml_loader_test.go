@@ -0,0 +1,78 @@
+package loader
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestYAMLLoader(t *testing.T) {
+	data := `# Shown on the home page
+hello: Bonjour
+"%d files":
+  one: "%d fichier"
+  other: "%d fichiers"
+menu:
+  open: Ouvrir
+  close: Fermer
+`
+	ldr := NewYAMLLoader()
+	tag := language.MustParse("fr-fr")
+	assert.Nil(t, ldr.ReadMessages(strings.NewReader(data), &tag, time.Now()))
+
+	cat, err := ldr.StringsByTag(tag)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"hello":      "Bonjour",
+		"%d files":   "%d fichiers",
+		"menu.open":  "Ouvrir",
+		"menu.close": "Fermer",
+	}, cat.Strings)
+	assert.Equal(t, map[string]string{MetadataComment: "Shown on the home page"}, cat.Metadata["hello"])
+	assert.Equal(t, "%d fichier", cat.Metadata["%d files"][MetadataPluralPrefix+"one"])
+}
+
+func TestYAMLLoaderRailsRoot(t *testing.T) {
+	ldr := NewYAMLLoader()
+	assert.Nil(t, ldr.ReadMessages(strings.NewReader("de:\n  hello: Hallo\n"), nil, time.Now()))
+
+	cat, err := ldr.StringsByTag(language.MustParse("de"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"hello": "Hallo"}, cat.Strings)
+}
+
+func TestYAMLLoaderRoundTrip(t *testing.T) {
+	tag := language.MustParse("pl-pl")
+	cat := NewStringCatalog(time.Now())
+	cat.Strings["hello"] = "Cześć: \"witaj\""
+	cat.Strings["%d files"] = "%d pliku"
+	cat.Metadata["hello"] = map[string]string{MetadataComment: "Greeting\non two lines"}
+	cat.Metadata["%d files"] = map[string]string{
+		MetadataPluralPrefix + "one":   "%d plik",
+		MetadataPluralPrefix + "few":   "%d pliki",
+		MetadataPluralPrefix + "other": "%d pliku",
+	}
+
+	var buf bytes.Buffer
+	_, err := WriteYAML(&buf, tag, language.AmericanEnglish, cat)
+	assert.Nil(t, err)
+
+	ldr := NewYAMLLoader()
+	assert.Nil(t, ldr.ReadMessages(&buf, &tag, time.Now()))
+	read, err := ldr.StringsByTag(tag)
+	assert.Nil(t, err)
+	assert.Equal(t, cat.Strings, read.Strings)
+	assert.Equal(t, cat.Metadata, read.Metadata)
+}
+
+func TestYAMLLoaderErrors(t *testing.T) {
+	tag := language.MustParse("fr-fr")
+	ldr := NewYAMLLoader()
+	assert.NotNil(t, ldr.ReadMessages(strings.NewReader("- a list"), &tag, time.Now()))
+	assert.NotNil(t, ldr.ReadMessages(strings.NewReader("hello: [a, b]"), &tag, time.Now()))
+	assert.NotNil(t, ldr.ReadMessages(strings.NewReader("hello: Bonjour"), nil, time.Now()))
+}
@@ -1,12 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"embed"
+	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	// Registers the sqlite3 database/sql driver for -sqlite.
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
@@ -14,31 +29,64 @@ import (
 
 	"github.com/scottmcmaster/go-loc-server/locserver/handlers"
 	"github.com/scottmcmaster/go-loc-server/locserver/loader"
+	"github.com/scottmcmaster/go-loc-server/locserver/loader/sqlitestore"
 )
 
-type loaderType string
-
-const (
-	goText loaderType = "gotext"
-	xliff2            = "xliff2"
-	po                = "po"
-)
-
-func (lt loaderType) IsValid() error {
-	switch lt {
-	case goText, xliff2, po:
-		return nil
-	}
-	return errors.New("invalid loader type")
-}
+// embeddedLocales lets the binary run without a locales directory; see the -embedded flag.
+//
+//go:embed locales-gotext locales-po locales-xliff2
+var embeddedLocales embed.FS
 
 var lang = flag.String("lang", "en-us", "use language")
-var localesDir = flag.String("localesdir", "./locales-gotext", "base directory of locale files")
+var cacheControl = flag.String("cachecontrol", "no-cache", "Cache-Control header of catalog responses, which have ETags to revalidate with")
+var responseCache = flag.Bool("responsecache", true, "keep serialized catalog responses until the locales are reloaded")
+var preGzip = flag.Bool("pregzip", false, "with -responsecache, also keep a gzip-compressed copy of each cached response")
+var compress = flag.Bool("compress", true, "compress responses in the encoding negotiated by Accept-Encoding")
+var compressMin = flag.Int("compressmin", handlers.DefaultCompressionMinSize, "smallest response body in bytes to compress")
+var langPrecedence = flag.String("langprecedence", strings.Join(handlers.DefaultLanguagePrecedence, ","), "comma-separated order of the places a request's language comes from: query, cookie and header (Accept-Language)")
+var langAliases = flag.String("langaliases", "", "comma-separated language aliases to match in place of the requested language, such as zh=zh-hans-cn,nb=no")
+var defaultLang = flag.String("defaultlang", "", "language to serve when a request matches none of the loaded languages (default the first loaded)")
+var missingKey = flag.String("missingkey", string(handlers.MissingKeyReturnKey), "what /v1/strings/{key} returns for a key without a translation: key, source (the -sourcelang text), 404, pseudo (pseudo-localized key) or empty")
+var trackMissing = flag.Bool("trackmissing", true, "record lookups of keys without a translation, served at /v1/missing")
+var trackUsage = flag.Bool("trackusage", true, "count lookups of each key in each language, served at /v1/usage")
+var usageFile = flag.String("usagefile", "", "file to keep the -trackusage counts in across restarts, and to read for the usage command")
+var usageSave = flag.Duration("usagesave", time.Minute, "how often to save the -trackusage counts to -usagefile")
+var sourceLang = flag.String("sourcelang", "en-us", "language whose strings define the keys and placeholders in /v1/strings.d.ts, and the completeness of the others; also the source language of XLIFF files written")
+var localesDir = flag.String("localesdir", "./locales-gotext", "base directory of locale files, a .zip, .tar or .tar.gz archive of one (which may be an http(s) URL), or an s3://bucket/prefix")
 var loaderTypeFl = flag.String("loader", "gotext", "loader type")
 var debug = flag.Bool("debug", false, "sets log level to debug")
 var server = flag.Bool("server", false, "starts in server mode")
 var port = flag.Int("port", 3001, "http port")
 var watch = flag.Bool("watch", true, "watch locales dir for changes and hot-reload")
+var pollInterval = flag.Duration("pollinterval", time.Minute, "how often to poll a remote -localesdir for changes")
+var gitRef = flag.String("gitref", "", "treat -localesdir as a git repository and serve the locales at this branch, tag or commit")
+var gitSubdir = flag.String("gitsubdir", "", "directory within the -gitref tree that holds the locales")
+var s3Endpoint = flag.String("s3endpoint", loader.DefaultS3Endpoint, "endpoint for an s3:// -localesdir; credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN")
+var s3Region = flag.String("s3region", "us-east-1", "region for an s3:// -localesdir")
+var embedded = flag.Bool("embedded", false, "load the locales embedded in the binary under the -localesdir name instead of from disk")
+var preferDisk = flag.Bool("preferdisk", false, "with -embedded, files in -localesdir override the embedded ones")
+var writable = flag.Bool("writable", false, "enables the PUT, PATCH, DELETE and import endpoints, which write changes to the files in -localesdir")
+var sqlitePath = flag.String("sqlite", "", "load catalogs from this SQLite database instead of -localesdir; \"import\" copies -localesdir into it")
+var execCmd = flag.String("execcmd", "", "command line run per file by the exec loader")
+var execExts = flag.String("execexts", "", "comma-separated file extensions handled by the exec loader in auto mode, such as toml,.ini")
+var execTimeout = flag.Duration("exectimeout", loader.DefaultExecTimeout, "timeout for each run of the exec loader command")
+
+// execExtList is -execexts, checked and normalized by main.
+var execExtList []string
+
+func init() {
+	loader.Register("exec", func() loader.Loader {
+		fields := strings.Fields(*execCmd)
+		if len(fields) == 0 {
+			return loader.NewExecLoader("", nil, *execTimeout)
+		}
+		return loader.NewExecLoader(fields[0], fields[1:], *execTimeout)
+	}, func(path string, head []byte) bool {
+		return len(execExtList) > 0 && loader.MatchExtensions(execExtList...)(path, head)
+	})
+
+	flag.Lookup("loader").Usage = "loader type, one of: " + strings.Join(loader.Names(), ", ")
+}
 
 func main() {
 	flag.Parse()
@@ -49,16 +97,43 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 
-	lt := loaderType(*loaderTypeFl)
-	if err := lt.IsValid(); err != nil {
-		log.Panic().Err(err).Msg("Loader type")
+	if *execExts != "" {
+		var err error
+		if execExtList, err = loader.ParseExtensions(*execExts); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -execexts")
+		}
+	}
+
+	switch flag.Arg(0) {
+	case "":
+	case "import":
+		importLocales()
+		return
+	case "convert":
+		if err := convert(flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("Conversion failed")
+		}
+		return
+	case "usage":
+		if err := usageReport(flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("Usage report failed")
+		}
+		return
+	default:
+		log.Fatal().Str("command", flag.Arg(0)).Msg("Unknown command")
 	}
-	ldr, err := createLoader(lt)
+
+	ldr, err := createLoader(*loaderTypeFl)
 	if err != nil {
 		log.Panic().Err(err).Msg("Loader")
 	}
 
-	strs, err := loader.NewStringTable(*localesDir, *watch, ldr)
+	var strs *loader.StringTable
+	if *sqlitePath != "" {
+		strs, err = createSQLiteStringTable()
+	} else {
+		strs, err = createStringTable(ldr)
+	}
 	if err != nil {
 		log.Panic().Err(err).Msg("StringTable")
 	}
@@ -69,6 +144,10 @@ func main() {
 	}
 	defer strs.Close()
 
+	if cl, ok := ldr.(*loader.CompositeLoader); ok {
+		logLoadReport(cl)
+	}
+
 	if *server {
 		startServer(strs, *port)
 	} else {
@@ -81,34 +160,396 @@ func main() {
 	}
 }
 
-func createLoader(lt loaderType) (loader.Loader, error) {
-	log.Info().Str("loaderType", string(lt)).Msg("Creating loader")
+func createLoader(name string) (loader.Loader, error) {
+	log.Info().Str("loaderType", name).Msg("Creating loader")
+	return loader.New(name)
+}
+
+func createStringTable(ldr loader.Loader) (*loader.StringTable, error) {
+	interval := *pollInterval
+	if !*watch {
+		interval = 0
+	}
+
+	if loader.IsURL(*localesDir) {
+		src, err := loader.NewHTTPSource(*localesDir, interval)
+		if err != nil {
+			return nil, err
+		}
+		return loader.NewStringTableSource(src, ldr)
+	}
+
+	if *gitRef != "" {
+		src, err := loader.NewGitSource(*localesDir, *gitRef, *gitSubdir, interval)
+		if err != nil {
+			return nil, err
+		}
+		return loader.NewStringTableSource(src, ldr)
+	}
+
+	if loader.IsS3URL(*localesDir) {
+		bucket, prefix, err := loader.ParseS3URL(*localesDir)
+		if err != nil {
+			return nil, err
+		}
+		src, err := loader.NewS3Source(*s3Endpoint, *s3Region, bucket, prefix,
+			os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"), interval)
+		if err != nil {
+			return nil, err
+		}
+		return loader.NewStringTableSource(src, ldr)
+	}
+
+	if loader.IsArchive(*localesDir) {
+		src, err := loader.NewArchiveSource(*localesDir, *watch)
+		if err != nil {
+			return nil, err
+		}
+		return loader.NewStringTableSource(src, ldr)
+	}
+
+	if !*embedded {
+		return loader.NewStringTable(*localesDir, *watch, ldr)
+	}
+
+	name := path.Clean(filepath.ToSlash(*localesDir))
+	log.Info().Str("localesdir", name).Bool("preferdisk", *preferDisk).Msg("Using embedded locales")
+	sub, err := fs.Sub(embeddedLocales, name)
+	if err != nil {
+		return nil, err
+	}
+
+	strs := loader.NewStringTableFS(sub, ldr)
+	if *preferDisk {
+		if err := strs.WithOverrides(*localesDir, *watch); err != nil {
+			return nil, err
+		}
+	}
+	return strs, nil
+}
+
+func createSQLiteStringTable() (*loader.StringTable, error) {
+	interval := *pollInterval
+	if !*watch {
+		interval = 0
+	}
+
+	store, err := sqlitestore.Open(*sqlitePath, interval)
+	if err != nil {
+		return nil, err
+	}
+	return loader.NewStringTableSource(store, loader.NewRecordsLoader())
+}
+
+// importLocales copies the catalogs under -localesdir into the -sqlite database.
+func importLocales() {
+	if *sqlitePath == "" {
+		log.Fatal().Msg("import needs -sqlite")
+	}
+
+	ldr, err := createLoader(*loaderTypeFl)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Loader")
+	}
+	*watch = false
+	strs, err := createStringTable(ldr)
+	if err != nil {
+		log.Fatal().Err(err).Msg("StringTable")
+	}
+	if err := strs.Load(); err != nil {
+		log.Fatal().Err(err).Msg("Fatal error while loading")
+	}
+	defer strs.Close()
+
+	store, err := sqlitestore.Open(*sqlitePath, 0)
+	if err != nil {
+		log.Fatal().Err(err).Str("sqlite", *sqlitePath).Msg("Failed to open catalog database")
+	}
+	defer store.Close()
+
+	count, err := store.Import(strs)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Import failed")
+	}
+	log.Info().Int("entries", count).Str("sqlite", *sqlitePath).Msg("Imported locales")
+}
+
+// usageReport prints the keys of each language in the locales that weren't looked up in a time window,
+// from the counts a server saved in -usagefile.
+func usageReport(args []string) error {
+	flags := flag.NewFlagSet("usage", flag.ExitOnError)
+	windowFl := flags.String("window", "", "report keys not looked up in this long, such as 30d or 36h; by default since counting began")
+	asJSON := flags.Bool("json", false, "print the report as JSON, as served at /v1/usage")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go-loc-server -usagefile file [-localesdir dir] usage [-window duration] [-json]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *usageFile == "" {
+		flags.Usage()
+		return errors.New("usage needs -usagefile")
+	}
+	var window time.Duration
+	if *windowFl != "" {
+		var err error
+		if window, err = handlers.ParseUsageWindow(*windowFl); err != nil {
+			return err
+		}
+	}
+
+	usage, err := handlers.LoadKeyUsage(*usageFile)
+	if err != nil {
+		return err
+	}
+	*watch = false
+	var strs *loader.StringTable
+	if *sqlitePath != "" {
+		strs, err = createSQLiteStringTable()
+	} else {
+		var ldr loader.Loader
+		if ldr, err = createLoader(*loaderTypeFl); err != nil {
+			return err
+		}
+		strs, err = createStringTable(ldr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := strs.Load(); err != nil {
+		return err
+	}
+	defer strs.Close()
+
+	report := usage.Report(strs, window)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	fmt.Printf("Keys not looked up since %s:\n", report.UnusedSince.Format(time.RFC3339))
+	for _, lu := range report.Languages {
+		fmt.Printf("%s: %d of %d keys (%d lookups in all)\n", lu.Language, len(lu.Unused), lu.Keys, lu.Hits)
+		for _, key := range lu.Unused {
+			fmt.Printf("  %s\n", key)
+		}
+	}
+	return nil
+}
+
+// convert reads a locale file in any format with a loader and writes it in another format.
+func convert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	langFl := flags.String("lang", "", "language of the input, for formats that don't name one (defaults to the parent directory), or to pick one from a file with several")
+	to := flags.String("to", "", "output format, one of: "+strings.Join(loader.WriterNames(), ", ")+"; by default from the output file extension")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go-loc-server convert [-lang tag] [-to format] input output")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("convert needs an input and an output file")
+	}
+	in, out := flags.Arg(0), flags.Arg(1)
+
+	var tag *language.Tag
+	if *langFl != "" {
+		t, err := language.Parse(*langFl)
+		if err != nil {
+			return err
+		}
+		tag = &t
+	}
+
+	format := *to
+	if format == "" {
+		var ok bool
+		if format, ok = loader.WriterForPath(out); !ok {
+			return errors.New("can't tell the output format from " + out + ", use -to")
+		}
+	}
+	writer, ok := loader.LookupWriter(format)
+	if !ok {
+		return errors.New("unknown output format " + format)
+	}
+
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	readTag := tag
+	if readTag == nil {
+		// Like locale directories, the parent directory can name the language.
+		if t, err := language.Parse(filepath.Base(filepath.Dir(in))); err == nil {
+			readTag = &t
+		}
+	}
+	cats, err := loader.ReadCatalogs(in, data, readTag)
+	if err != nil {
+		return err
+	}
 
-	switch lt {
-	case goText:
-		return loader.NewGoTextJSONLoader(), nil
-	case xliff2:
-		return loader.NewXLIFF2Loader(), nil
-	case po:
-		return loader.NewPOLoader(), nil
+	var cat *loader.StringCatalog
+	var catTag language.Tag
+	for t, c := range cats {
+		if tag == nil || t == *tag {
+			if cat != nil {
+				return errors.New(in + " has several languages, use -lang to pick one")
+			}
+			cat, catTag = c, t
+		}
+	}
+	if cat == nil {
+		return errors.New(in + " has no strings for the language")
 	}
 
-	return nil, errors.New("unknown loader type " + string(lt))
+	var buf bytes.Buffer
+	warnings, err := writer.Write(&buf, catTag, language.Make(*sourceLang), cat)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		log.Warn().Str("format", format).Msg(w)
+	}
+	if out == "-" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := ioutil.WriteFile(out, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	log.Info().Str("input", in).Str("output", out).Str("language_tag", catTag.String()).
+		Int("strings", len(cat.Strings)).Msg("Converted")
+	return nil
+}
+
+func logLoadReport(cl *loader.CompositeLoader) {
+	for _, r := range cl.Report() {
+		if r.Loader == "" {
+			log.Info().Str("path", r.Path).Msg("Ignored file with unknown format")
+		} else if r.Err != nil {
+			log.Warn().Str("path", r.Path).Str("loader", r.Loader).Err(r.Err).Msg("Failed to load file")
+		} else {
+			log.Info().Str("path", r.Path).Str("loader", r.Loader).Msg("Loaded file")
+		}
+	}
+}
+
+// saveUsageOnExit saves the key usage counts when the server is interrupted or terminated, since they are
+// otherwise only saved every -usagesave.
+func saveUsageOnExit(usage *handlers.KeyUsage) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if err := usage.Close(); err != nil {
+			log.Error().Err(err).Str("usagefile", usage.Path).Msg("Failed to save key usage")
+		}
+		os.Exit(0)
+	}()
 }
 
 func startServer(strs *loader.StringTable, port int) {
+	aliases := map[string]string{}
+	for _, alias := range strings.Split(*langAliases, ",") {
+		if alias == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(alias, "=")
+		if !ok {
+			log.Fatal().Str("alias", alias).Msg("Invalid -langaliases, expected from=to")
+		}
+		aliases[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	}
+	negotiator, err := handlers.NewNegotiator(strings.Split(*langPrecedence, ","), aliases, *defaultLang)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid -langprecedence, -langaliases or -defaultlang")
+	}
+	if err := negotiator.CheckDefault(strs); err != nil {
+		log.Fatal().Err(err).Msg("Invalid -defaultlang")
+	}
+	missingKeyPolicy, err := handlers.ParseMissingKeyPolicy(*missingKey)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid -missingkey")
+	}
+
 	mux := mux.NewRouter()
 
+	if *writable {
+		mux.Handle("/v1/strings/{str}", handlers.EditStringHandler{ST: strs}).Methods(http.MethodPut, http.MethodDelete)
+		mux.Handle("/v1/strings", handlers.EditStringsHandler{ST: strs}).Methods(http.MethodPatch)
+		mux.Handle("/v1/import", handlers.ImportHandler{ST: strs}).Methods(http.MethodPost)
+	}
+
+	var usage *handlers.KeyUsage
+	if *trackUsage {
+		var err error
+		if usage, err = handlers.NewKeyUsage(*usageFile, *usageSave); err != nil {
+			log.Fatal().Err(err).Str("usagefile", *usageFile).Msg("Failed to load key usage")
+		}
+		saveUsageOnExit(usage)
+		mux.Handle("/v1/usage", handlers.UsageHandler{ST: strs, Usage: usage})
+	}
+
+	var missing *handlers.MissingKeys
+	if *trackMissing {
+		missing = handlers.NewMissingKeys()
+		mHandler := handlers.MissingHandler{
+			ST:         strs,
+			Missing:    missing,
+			Negotiator: negotiator,
+		}
+		mux.Handle("/v1/missing", mHandler).Methods(http.MethodGet, http.MethodPost)
+	}
+
+	mux.Handle("/v1/strings:batch", handlers.BatchHandler{ST: strs, Missing: missing, Usage: usage, Negotiator: negotiator}).Methods(http.MethodPost)
+
 	sHandler := handlers.StringHandler{
-		ST: strs,
+		ST:         strs,
+		MissingKey: missingKeyPolicy,
+		SourceLang: *sourceLang,
+		Missing:    missing,
+		Usage:      usage,
+		Negotiator: negotiator,
 	}
 	mux.Handle("/v1/strings/{str}", sHandler)
 
+	tHandler := handlers.TypesHandler{
+		ST:           strs,
+		SourceLang:   *sourceLang,
+		CacheControl: *cacheControl,
+	}
+	mux.Handle("/v1/strings.d.ts", tHandler)
+
 	ssHandler := handlers.StringsHandler{
-		ST: strs,
+		ST:           strs,
+		CacheControl: *cacheControl,
+		SourceLang:   *sourceLang,
+		Negotiator:   negotiator,
+	}
+	if *responseCache {
+		ssHandler.Cache = handlers.NewResponseCache(strs, *preGzip)
 	}
 	mux.Handle("/v1/strings", ssHandler)
 
+	mux.Handle("/v1/negotiate", handlers.NegotiateHandler{ST: strs, Negotiator: negotiator})
+
+	lHandler := handlers.LanguagesHandler{
+		ST:         strs,
+		SourceLang: *sourceLang,
+	}
+	mux.Handle("/v1/languages", lHandler)
+
+	stHandler := handlers.StatusHandler{
+		ST: strs,
+	}
+	mux.Handle("/v1/status", stHandler)
+
+	mux.Use(handlers.RevisionMiddleware(strs))
+	if *compress {
+		mux.Use(handlers.CompressionMiddleware(*compressMin))
+	}
+
 	//Create the server.
 	log.Info().
 		Int("port", port).
@@ -118,7 +559,7 @@ func startServer(strs *loader.StringTable, port int) {
 		Addr:    ":" + strconv.Itoa(port),
 		Handler: mux,
 	}
-	err := s.ListenAndServe()
+	err = s.ListenAndServe()
 	if err != nil {
 		log.Fatal().Int("port", port).Err(err).Msg("Failed to start server")
 	}